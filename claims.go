@@ -0,0 +1,124 @@
+package goth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Audience is the aud claim of a JWT, which may be either a single string or
+// an array of strings.
+type Audience []string
+
+// UnmarshalJSON accepts both forms of the aud claim.
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("aud claim must be a string or an array of strings")
+	}
+	*a = list
+	return nil
+}
+
+// Contains reports whether aud is one of the audiences.
+func (a Audience) Contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// FlexBool is a boolean claim that some providers, e.g. Apple, send as the
+// string "true" or "false".
+type FlexBool bool
+
+// UnmarshalJSON accepts both booleans and their string form.
+func (b *FlexBool) UnmarshalJSON(data []byte) error {
+	var v bool
+	if err := json.Unmarshal(data, &v); err == nil {
+		*b = FlexBool(v)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New("claim must be a boolean or a string")
+	}
+	*b = s == "true"
+	return nil
+}
+
+// StandardClaims are the ID token claims common to OpenID Connect providers.
+// See https://openid.net/specs/openid-connect-core-1_0.html#IDToken and
+// https://openid.net/specs/openid-connect-core-1_0.html#StandardClaims
+type StandardClaims struct {
+	Subject       string   `json:"sub"`
+	Issuer        string   `json:"iss"`
+	Audience      Audience `json:"aud"`
+	ExpiresAt     int64    `json:"exp"`
+	IssuedAt      int64    `json:"iat"`
+	AuthTime      int64    `json:"auth_time"`
+	Nonce         string   `json:"nonce"`
+	Email         string   `json:"email"`
+	EmailVerified FlexBool `json:"email_verified"`
+	Name          string   `json:"name"`
+	GivenName     string   `json:"given_name"`
+	FamilyName    string   `json:"family_name"`
+	Picture       string   `json:"picture"`
+	Locale        string   `json:"locale"`
+	// HostedDomain is the Google Workspace domain of the user.
+	HostedDomain string `json:"hd"`
+}
+
+// ErrReauthRequired is returned by providers given a maximum authentication
+// age, with the max_age parameter, when the ID token shows that the user
+// authenticated longer ago than that. Send the user through the provider
+// again to get a fresh authentication.
+var ErrReauthRequired = errors.New("the user must authenticate again")
+
+// CheckAuthAge returns ErrReauthRequired unless the auth_time claim shows that
+// the user authenticated at most maxAge ago, give or take leeway for clock
+// skew. A missing auth_time claim fails the check too.
+func (c StandardClaims) CheckAuthAge(maxAge, leeway time.Duration) error {
+	if c.AuthTime == 0 {
+		return ErrReauthRequired
+	}
+	if time.Since(time.Unix(c.AuthTime, 0)) > maxAge+leeway {
+		return ErrReauthRequired
+	}
+	return nil
+}
+
+// ParseUnverifiedClaims decodes the claims of a JWT, typically an ID token,
+// both as StandardClaims and as a map holding every claim. The signature is
+// NOT verified: only use it on tokens received directly from the provider's
+// token endpoint, or verify them first.
+func ParseUnverifiedClaims(idToken string) (StandardClaims, map[string]interface{}, error) {
+	var claims StandardClaims
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return claims, nil, errors.New("malformed JWT: expected 3 parts")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return claims, nil, fmt.Errorf("malformed JWT: %w", err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return claims, nil, fmt.Errorf("malformed JWT claims: %w", err)
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, nil, fmt.Errorf("malformed JWT claims: %w", err)
+	}
+	return claims, raw, nil
+}
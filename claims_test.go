@@ -0,0 +1,50 @@
+package goth_test
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func jwtWithPayload(payload string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString([]byte(payload)) + ".signature"
+}
+
+func Test_ParseUnverifiedClaims(t *testing.T) {
+	a := assert.New(t)
+
+	claims, raw, err := goth.ParseUnverifiedClaims(jwtWithPayload(`{"sub":"1234","iss":"https://accounts.google.com","aud":"client","exp":1700000000,"email":"homer@example.com","email_verified":true,"hd":"example.com","custom":"value"}`))
+	a.NoError(err)
+	a.Equal("1234", claims.Subject)
+	a.Equal(goth.Audience{"client"}, claims.Audience)
+	a.True(claims.Audience.Contains("client"))
+	a.Equal(int64(1700000000), claims.ExpiresAt)
+	a.True(bool(claims.EmailVerified))
+	a.Equal("example.com", claims.HostedDomain)
+	a.Equal("value", raw["custom"])
+
+	claims, _, err = goth.ParseUnverifiedClaims(jwtWithPayload(`{"aud":["one","two"],"email_verified":"true"}`))
+	a.NoError(err)
+	a.True(claims.Audience.Contains("two"))
+	a.False(claims.Audience.Contains("three"))
+	a.True(bool(claims.EmailVerified))
+
+	_, _, err = goth.ParseUnverifiedClaims("not.a-jwt")
+	a.Error(err)
+	_, _, err = goth.ParseUnverifiedClaims(jwtWithPayload(`{"aud":1}`))
+	a.Error(err)
+}
+
+func Test_CheckAuthAge(t *testing.T) {
+	a := assert.New(t)
+
+	claims := goth.StandardClaims{AuthTime: time.Now().Add(-time.Minute).Unix()}
+	a.NoError(claims.CheckAuthAge(5*time.Minute, 0))
+	a.ErrorIs(claims.CheckAuthAge(30*time.Second, 0), goth.ErrReauthRequired)
+	a.NoError(claims.CheckAuthAge(30*time.Second, time.Minute))
+	a.ErrorIs(goth.StandardClaims{}.CheckAuthAge(time.Hour, 0), goth.ErrReauthRequired)
+}
@@ -0,0 +1,12 @@
+package gothic
+
+import "testing"
+
+// RestoreKeys saves the session store and the keys set through SetKeys and
+// restores them when the test finishes.
+func RestoreKeys(t *testing.T) {
+	store, def, set, keys := Store, defaultStore, keySet, signingKeys
+	t.Cleanup(func() {
+		Store, defaultStore, keySet, signingKeys = store, def, set, keys
+	})
+}
@@ -21,6 +21,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
@@ -36,21 +37,216 @@ var defaultStore sessions.Store
 
 var keySet = false
 
+// signingKeys are the keys given to SetKeys, the current one first.
+var signingKeys [][]byte
+
 type key int
 
-// ProviderParamKey can be used as a key in context when passing in a provider
-const ProviderParamKey key = iota
+const (
+	// ProviderParamKey can be used as a key in context when passing in a provider
+	ProviderParamKey key = iota
+	// explicitProviderKey holds the provider given to the *For functions, it
+	// takes precedence over any other source of provider name.
+	explicitProviderKey
+
+	// userKey is the context key under which Middleware stores the user.
+	userKey
+)
+
+// Timeout bounds how long gothic waits on a provider when beginning the
+// authentication process, exchanging the code and fetching the user, so that
+// a slow provider does not hang the request indefinitely. It applies to the
+// provider calls that accept a context, such as goth.ContextBeginner,
+// goth.ContextAuthorizer and goth.ContextFetcher, and to PromptProvider: the
+// others cannot be stopped and are waited for. Zero waits for as long as the
+// request itself is alive.
+var Timeout = 30 * time.Second
+
+// StateTTL limits how long the state stored by GetAuthURL stays valid. Once
+// it has passed, CompleteUserAuth fails with ErrStateExpired. When set, the
+// state is kept in a session of its own whose MaxAge is StateTTL, which
+// makes the cookie expire with the default store and sets the record expiry
+// of server-side stores, so abandoned logins do not pile up; the MaxAge of
+// the gothic session is left alone. Zero, the default, keeps the state in the
+// gothic session and never expires it.
+var StateTTL time.Duration
+
+// ErrStateExpired is returned by CompleteUserAuth when the authentication
+// process was started more than StateTTL ago.
+var ErrStateExpired = errors.New("gothic: the authentication state has expired")
+
+// stateSessionName is the name of the session holding the state when
+// StateTTL is set.
+const stateSessionName = SessionName + "_state"
+
+// stateExpirySuffix is appended to the provider name to get the session key
+// holding the expiry of its state.
+const stateExpirySuffix = "_expires_at"
+
+// TimeoutError is returned by gothic when a provider did not answer within
+// Timeout. Middleware can use StatusCode to map it to the right response.
+type TimeoutError struct {
+	Provider string
+	Op       string
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("gothic: %s timed out waiting for provider %s", e.Op, e.Provider)
+}
+
+// StatusCode returns the HTTP status that best describes the error.
+func (e *TimeoutError) StatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
+// ErrUnknownProvider is returned by gothic when the request names a provider
+// that has not been registered with goth.UseProviders, e.g. a mistyped
+// provider in the URL.
+type ErrUnknownProvider struct {
+	Name string
+}
+
+func (e *ErrUnknownProvider) Error() string {
+	return fmt.Sprintf("no provider for %s exists", e.Name)
+}
+
+// StatusCode returns the HTTP status that best describes the error.
+func (e *ErrUnknownProvider) StatusCode() int {
+	return http.StatusNotFound
+}
+
+// getProvider returns the provider registered as name.
+func getProvider(name string) (goth.Provider, error) {
+	provider, err := goth.GetProvider(name)
+	if err != nil {
+		return nil, &ErrUnknownProvider{Name: name}
+	}
+	return provider, nil
+}
+
+// ProviderAuthError is returned by CompleteUserAuth when the provider
+// redirected back with an error instead of an authorization code, e.g. when
+// the user denied access or an administrator policy blocked the sign in.
+// See https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.2.1
+type ProviderAuthError struct {
+	Provider    string
+	Code        string // the "error" parameter, e.g. "access_denied"
+	Description string // the optional "error_description" parameter
+	URI         string // the optional "error_uri" parameter
+}
+
+func (e *ProviderAuthError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("gothic: %s could not sign you in: %s (%s)", e.Provider, e.Description, e.Code)
+	}
+	return fmt.Sprintf("gothic: %s could not sign you in: %s", e.Provider, e.Code)
+}
+
+// StatusCode returns the HTTP status that best describes the error.
+func (e *ProviderAuthError) StatusCode() int {
+	return http.StatusUnauthorized
+}
+
+// Errors matched by errors.Is when a silent authentication, started with
+// prompt=none, could not complete without showing the user a page. See
+// https://openid.net/specs/openid-connect-core-1_0.html#AuthError
+var (
+	// ErrLoginRequired means the user is not signed in to the provider:
+	// begin the authentication again without prompt=none.
+	ErrLoginRequired = errors.New("gothic: login required")
+	// ErrConsentRequired means the user has not granted the requested
+	// access yet: begin again with prompt=consent.
+	ErrConsentRequired = errors.New("gothic: consent required")
+	// ErrAccountSelectionRequired means the user is signed in to several
+	// accounts: begin again with prompt=select_account to show the account
+	// chooser.
+	ErrAccountSelectionRequired = errors.New("gothic: account selection required")
+	// ErrInteractionRequired means the provider needs the user for another
+	// reason: begin again without prompt=none.
+	ErrInteractionRequired = errors.New("gothic: interaction required")
+)
+
+// silentAuthErrors maps the error codes of silent authentications to their
+// sentinel error.
+var silentAuthErrors = map[string]error{
+	"login_required":             ErrLoginRequired,
+	"consent_required":           ErrConsentRequired,
+	"account_selection_required": ErrAccountSelectionRequired,
+	"interaction_required":       ErrInteractionRequired,
+}
+
+// Is lets errors.Is match the error codes of silent authentications, e.g.
+// ErrAccountSelectionRequired.
+func (e *ProviderAuthError) Is(target error) bool {
+	sentinel, ok := silentAuthErrors[e.Code]
+	return ok && target == sentinel
+}
 
 func init() {
-	key := []byte(os.Getenv("SESSION_SECRET"))
-	keySet = len(key) != 0
+	SetKeys([]byte(os.Getenv("SESSION_SECRET")))
+}
 
-	cookieStore := sessions.NewCookieStore(key)
+/*
+SetKeys configures the default cookie store, and makes it the Store in use.
+Sessions are signed with the current key, while sessions signed with any
+of the old keys are still accepted. This allows rotating the secret without
+logging everyone out: deploy with SetKeys(newKey, oldKey), and drop the old
+key once the sessions signed with it have expired.
+
+The default store reads its key from the SESSION_SECRET environment variable.
+*/
+func SetKeys(current []byte, old ...[]byte) {
+	keyPairs := [][]byte{current, nil}
+	for _, key := range old {
+		keyPairs = append(keyPairs, key, nil)
+	}
+
+	cookieStore := sessions.NewCookieStore(keyPairs...)
 	cookieStore.Options.HttpOnly = true
+	// the cookie must be sent back on the redirect from the provider
+	cookieStore.Options.SameSite = http.SameSiteLaxMode
+	if previous, ok := defaultStore.(*sessions.CookieStore); ok {
+		// keep any options that were set on the previous default store,
+		// applying MaxAge to the signature checks of the new keys too
+		*cookieStore.Options = *previous.Options
+		cookieStore.MaxAge(previous.Options.MaxAge)
+	}
+
+	keySet = len(current) != 0
+	signingKeys = append([][]byte{current}, old...)
 	Store = cookieStore
 	defaultStore = Store
 }
 
+/*
+SetCookieOptions sets the attributes of the cookies written by the default
+store, for example to share them with subdomains or to only send them over
+https:
+
+	gothic.SetCookieOptions(sessions.Options{
+		Path:     "/",
+		Domain:   "example.com",
+		MaxAge:   86400 * 30,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+SameSite must not be http.SameSiteStrictMode: the browser would not send the
+cookie on the redirect back from the provider, and the callback would fail
+to find the session. Secure should be enabled in production.
+It has no effect when Store has been replaced by another store.
+*/
+func SetCookieOptions(options sessions.Options) {
+	cookieStore, ok := defaultStore.(*sessions.CookieStore)
+	if !ok {
+		return
+	}
+	*cookieStore.Options = options
+	// also applies MaxAge to the signature checks
+	cookieStore.MaxAge(options.MaxAge)
+}
+
 /*
 BeginAuthHandler is a convenience handler for starting the authentication process.
 It expects to be able to get the name of the provider from the query parameters
@@ -64,14 +260,44 @@ See https://github.com/markbates/goth/blob/master/examples/main.go to see this i
 func BeginAuthHandler(res http.ResponseWriter, req *http.Request) {
 	url, err := GetAuthURL(res, req)
 	if err != nil {
-		res.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintln(res, err)
+		handleError(res, req, err)
 		return
 	}
 
 	http.Redirect(res, req, url, http.StatusTemporaryRedirect)
 }
 
+/*
+ErrorHandler is called by BeginAuthHandler and Middleware to write the
+response when authentication fails. It can be replaced to render the
+application's own error page or API error shape. When nil, the error is
+written as text with a 400 status, or the StatusCode of a TimeoutError,
+ProviderAuthError or ErrUnknownProvider.
+*/
+var ErrorHandler func(res http.ResponseWriter, req *http.Request, err error)
+
+func handleError(res http.ResponseWriter, req *http.Request, err error) {
+	if ErrorHandler != nil {
+		ErrorHandler(res, req, err)
+		return
+	}
+
+	var te *TimeoutError
+	var pe *ProviderAuthError
+	var ue *ErrUnknownProvider
+	switch {
+	case errors.As(err, &te):
+		res.WriteHeader(te.StatusCode())
+	case errors.As(err, &pe):
+		res.WriteHeader(pe.StatusCode())
+	case errors.As(err, &ue):
+		res.WriteHeader(ue.StatusCode())
+	default:
+		res.WriteHeader(http.StatusBadRequest)
+	}
+	fmt.Fprintln(res, err)
+}
+
 // SetState sets the state string associated with the given request.
 // If no state string is associated with the request, one will be generated.
 // This state is sent to the provider and can be retrieved during the
@@ -117,36 +343,60 @@ I would recommend using the BeginAuthHandler instead of doing all of these steps
 yourself, but that's entirely up to you.
 */
 func GetAuthURL(res http.ResponseWriter, req *http.Request) (string, error) {
+	url, _, err := GetAuthURLWithState(res, req)
+	return url, err
+}
+
+// GetAuthURLWithState works like GetAuthURL but also returns the state that
+// was sent to the provider, so applications can correlate the round trip
+// with their own records. The state is still stored in the session and
+// validated by CompleteUserAuth as usual.
+func GetAuthURLWithState(res http.ResponseWriter, req *http.Request) (string, string, error) {
 	if !keySet && defaultStore == Store {
 		fmt.Println("goth/gothic: no SESSION_SECRET environment variable is set. The default cookie store is not available and any calls will fail. Ignore this warning if you are using a different store.")
 	}
 
 	providerName, err := GetProviderName(req)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	provider, err := goth.GetProvider(providerName)
+	provider, err := getProvider(providerName)
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+
+	state := SetState(req)
+	var sess goth.Session
+	if pp, ok := provider.(PromptProvider); ok && !seenBefore(req) {
+		err = withTimeout(req, providerName, "BeginAuth", func(context.Context) (err error) {
+			sess, err = pp.BeginAuthWithPrompt(state, "select_account")
+			return err
+		})
+	} else if _, ok := provider.(goth.ContextBeginner); ok {
+		err = withTimeout(req, providerName, "BeginAuth", func(ctx context.Context) (err error) {
+			sess, err = goth.BeginAuthContext(ctx, provider, state)
+			return err
+		})
+	} else {
+		sess, err = provider.BeginAuth(state)
 	}
-	sess, err := provider.BeginAuth(SetState(req))
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	url, err := sess.GetAuthURL()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	err = StoreInSession(providerName, sess.Marshal(), req, res)
+	err = storeState(providerName, sess.Marshal(), req, res)
 
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	return url, err
+	return url, state, err
 }
 
 /*
@@ -168,30 +418,62 @@ var CompleteUserAuth = func(res http.ResponseWriter, req *http.Request) (goth.Us
 		return goth.User{}, err
 	}
 
-	provider, err := goth.GetProvider(providerName)
+	provider, err := getProvider(providerName)
 	if err != nil {
 		return goth.User{}, err
 	}
 
-	value, err := GetFromSession(providerName, req)
+	value, err := getState(providerName, req)
 	if err != nil {
 		return goth.User{}, err
 	}
 	defer Logout(res, req)
-	sess, err := provider.UnmarshalSession(value)
+	sess, params, err := checkCallback(req, providerName, provider, value)
 	if err != nil {
 		return goth.User{}, err
 	}
 
-	err = validateState(req, sess)
+	user, err := fetchUser(req, providerName, provider, sess)
+	if err == nil {
+		// user can be found with existing session data
+		markSeen(res, req)
+		return user, err
+	}
+
+	// get new token and retry fetch
+	err = authorize(req, providerName, provider, sess, params)
 	if err != nil {
 		return goth.User{}, err
 	}
 
-	user, err := provider.FetchUser(sess)
+	err = StoreInSession(providerName, sess.Marshal(), req, res)
+
+	if err != nil {
+		return goth.User{}, err
+	}
+
+	gu, err := fetchUser(req, providerName, provider, sess)
 	if err == nil {
-		// user can be found with existing session data
-		return user, err
+		markSeen(res, req)
+	}
+	return gu, err
+}
+
+// checkCallback restores the session stored by BeginAuthHandler from value
+// and checks that the callback request belongs to it. It returns the session
+// and the callback parameters.
+func checkCallback(req *http.Request, providerName string, provider goth.Provider, value string) (goth.Session, url.Values, error) {
+	if err := checkStateExpiry(req, providerName); err != nil {
+		return nil, nil, err
+	}
+	sess, err := provider.UnmarshalSession(value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = validateState(req, sess)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	params := req.URL.Query()
@@ -200,20 +482,117 @@ var CompleteUserAuth = func(res http.ResponseWriter, req *http.Request) (goth.Us
 		params = req.Form
 	}
 
-	// get new token and retry fetch
-	_, err = sess.Authorize(provider, params)
-	if err != nil {
-		return goth.User{}, err
+	if code := params.Get("error"); code != "" {
+		// there is no authorization code to exchange
+		return nil, nil, &ProviderAuthError{
+			Provider:    providerName,
+			Code:        code,
+			Description: params.Get("error_description"),
+			URI:         params.Get("error_uri"),
+		}
 	}
+	return sess, params, nil
+}
 
-	err = StoreInSession(providerName, sess.Marshal(), req, res)
+// authorize authorizes sess, within Timeout when it implements
+// goth.ContextAuthorizer.
+func authorize(req *http.Request, providerName string, provider goth.Provider, sess goth.Session, params goth.Params) error {
+	if _, ok := sess.(goth.ContextAuthorizer); !ok {
+		_, err := sess.Authorize(provider, params)
+		return err
+	}
+	return withTimeout(req, providerName, "Authorize", func(ctx context.Context) error {
+		_, err := goth.AuthorizeContext(ctx, sess, provider, params)
+		return err
+	})
+}
 
-	if err != nil {
-		return goth.User{}, err
+// fetchUser fetches the user of sess, within Timeout when provider
+// implements goth.ContextFetcher.
+func fetchUser(req *http.Request, providerName string, provider goth.Provider, sess goth.Session) (user goth.User, err error) {
+	if _, ok := provider.(goth.ContextFetcher); !ok {
+		return provider.FetchUser(sess)
 	}
+	err = withTimeout(req, providerName, "FetchUser", func(ctx context.Context) (err error) {
+		user, err = goth.FetchUserContext(ctx, provider, sess)
+		return err
+	})
+	return user, err
+}
 
-	gu, err := provider.FetchUser(sess)
-	return gu, err
+// withTimeout runs fn, giving up once Timeout has passed or the request has
+// been cancelled. fn is given a context ending at the same time and must stop
+// once it is done: only use withTimeout for provider calls accepting a
+// context, or that never block, like PromptProvider's. A panic in fn is
+// returned as an error.
+func withTimeout(req *http.Request, providerName, op string, fn func(ctx context.Context) error) error {
+	if Timeout <= 0 {
+		return fn(req.Context())
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), Timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("gothic: %s panicked in provider %s: %v", op, providerName, r)
+			}
+		}()
+		done <- fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return &TimeoutError{Provider: providerName, Op: op}
+		}
+		return ctx.Err()
+	}
+}
+
+// IsCallbackRequest reports whether Middleware should complete the
+// authentication process for the given request. By default this is any
+// request whose path ends in "/callback", e.g. "/auth/google/callback".
+// Assign your own function to this variable to use a different URL scheme.
+var IsCallbackRequest = func(req *http.Request) bool {
+	return strings.HasSuffix(req.URL.Path, "/callback")
+}
+
+/*
+Middleware completes the authentication process on callback requests (see
+IsCallbackRequest) and stores the resulting goth.User in the request context,
+where the next handler can get it with UserFromContext. The provider is
+selected with GetProviderName, as for CompleteUserAuth. Other requests are
+passed through untouched.
+
+If the authentication fails, the error is passed to ErrorHandler and next
+is not called.
+*/
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if !IsCallbackRequest(req) {
+			next.ServeHTTP(res, req)
+			return
+		}
+
+		user, err := CompleteUserAuth(res, req)
+		if err != nil {
+			handleError(res, req, err)
+			return
+		}
+
+		next.ServeHTTP(res, req.WithContext(context.WithValue(req.Context(), userKey, user)))
+	})
+}
+
+// UserFromContext returns the user stored in the context by Middleware.
+func UserFromContext(ctx context.Context) (goth.User, bool) {
+	user, ok := ctx.Value(userKey).(goth.User)
+	return user, ok
 }
 
 // validateState ensures that the state token param from the original
@@ -238,17 +617,37 @@ func validateState(req *http.Request, sess goth.Session) error {
 	return nil
 }
 
-// Logout invalidates a user session.
+// Logout invalidates a user session. The session is saved with a negative
+// MaxAge, which expires the cookie and makes server-side stores (filesystem,
+// Redis, ...) delete the record backing it.
 func Logout(res http.ResponseWriter, req *http.Request) error {
+	if session, _ := Store.Get(req, stateSessionName); session != nil && !session.IsNew {
+		if err := expireSession(req, res, session); err != nil {
+			return err
+		}
+	}
+
+	// the session is deleted even when the store reports it as invalid
 	session, err := Store.Get(req, SessionName)
-	if err != nil {
+	if session == nil {
 		return err
 	}
-	session.Options.MaxAge = -1
+	return expireSession(req, res, session)
+}
+
+// expireSession saves session empty and with a negative MaxAge.
+func expireSession(req *http.Request, res http.ResponseWriter, session *sessions.Session) error {
+
+	// copy the options, some stores share them between sessions
+	options := sessions.Options{}
+	if session.Options != nil {
+		options = *session.Options
+	}
+	options.MaxAge = -1
+	session.Options = &options
 	session.Values = make(map[interface{}]interface{})
-	err = session.Save(req, res)
-	if err != nil {
-		return errors.New("Could not delete user session ")
+	if err := session.Save(req, res); err != nil {
+		return fmt.Errorf("Could not delete user session: %w", err)
 	}
 	return nil
 }
@@ -262,6 +661,11 @@ var GetProviderName = getProviderName
 
 func getProviderName(req *http.Request) (string, error) {
 
+	// the provider given to BeginAuthHandlerFor or CompleteUserAuthFor
+	if p, ok := req.Context().Value(explicitProviderKey).(string); ok {
+		return p, nil
+	}
+
 	// try to get it from the url param "provider"
 	if p := req.URL.Query().Get("provider"); p != "" {
 		return p, nil
@@ -287,6 +691,13 @@ func getProviderName(req *http.Request) (string, error) {
 		return p, nil
 	}
 
+	// try to get it from a state made with EncodeState
+	if state := GetState(req); state != "" {
+		if p, _, err := DecodeState(state); err == nil {
+			return p, nil
+		}
+	}
+
 	// As a fallback, loop over the used providers, if we already have a valid session for any provider (ie. user has already begun authentication with a provider), then return that provider name
 	providers := goth.GetProviders()
 	session, _ := Store.Get(req, SessionName)
@@ -302,6 +713,63 @@ func getProviderName(req *http.Request) (string, error) {
 	return "", errors.New("you must select a provider")
 }
 
+/*
+ProviderFromPath extracts the provider name from the path of the request,
+using a pattern where the provider is the "{provider}" segment, such as
+"/auth/{provider}/callback". Other "{name}" segments match any value. This
+lets the provider be part of the path with any router, including
+http.ServeMux:
+
+	mux.HandleFunc("/auth/", func(res http.ResponseWriter, req *http.Request) {
+		provider, err := gothic.ProviderFromPath(req, "/auth/{provider}")
+		if err != nil {
+			http.NotFound(res, req)
+			return
+		}
+		gothic.BeginAuthHandlerFor(provider).ServeHTTP(res, req)
+	})
+*/
+func ProviderFromPath(req *http.Request, pattern string) (string, error) {
+	path := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	segments := strings.Split(strings.Trim(pattern, "/"), "/")
+	if len(path) != len(segments) {
+		return "", fmt.Errorf("path %q does not match %q", req.URL.Path, pattern)
+	}
+
+	provider := ""
+	for i, segment := range segments {
+		switch {
+		case segment == "{provider}":
+			provider = path[i]
+		case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"):
+		case segment != path[i]:
+			return "", fmt.Errorf("path %q does not match %q", req.URL.Path, pattern)
+		}
+	}
+	if provider == "" {
+		return "", errors.New("you must select a provider")
+	}
+	return provider, nil
+}
+
+// BeginAuthHandlerFor returns a handler behaving like BeginAuthHandler for
+// the given provider, wherever the provider name comes from.
+func BeginAuthHandlerFor(provider string) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		BeginAuthHandler(res, withExplicitProvider(req, provider))
+	})
+}
+
+// CompleteUserAuthFor behaves like CompleteUserAuth for the given provider,
+// wherever the provider name comes from.
+func CompleteUserAuthFor(res http.ResponseWriter, req *http.Request, provider string) (goth.User, error) {
+	return CompleteUserAuth(res, withExplicitProvider(req, provider))
+}
+
+func withExplicitProvider(req *http.Request, provider string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), explicitProviderKey, provider))
+}
+
 // GetContextWithProvider returns a new request context containing the provider
 func GetContextWithProvider(req *http.Request, provider string) *http.Request {
 	return req.WithContext(context.WithValue(req.Context(), ProviderParamKey, provider))
@@ -318,6 +786,54 @@ func StoreInSession(key string, value string, req *http.Request, res http.Respon
 	return session.Save(req, res)
 }
 
+// storeState works like StoreInSession, but when StateTTL is set stores the
+// state in a session of its own, expiring after StateTTL, along with its
+// expiry.
+func storeState(providerName, value string, req *http.Request, res http.ResponseWriter) error {
+	if StateTTL <= 0 {
+		return StoreInSession(providerName, value, req, res)
+	}
+
+	session, _ := Store.New(req, stateSessionName)
+	if err := updateSessionValue(session, providerName, value); err != nil {
+		return err
+	}
+	session.Values[providerName+stateExpirySuffix] = time.Now().Add(StateTTL).Unix()
+	// copy the options, some stores share them between sessions
+	options := sessions.Options{}
+	if session.Options != nil {
+		options = *session.Options
+	}
+	options.MaxAge = int((StateTTL + time.Second - 1) / time.Second)
+	session.Options = &options
+
+	return session.Save(req, res)
+}
+
+// getState returns the state stored by storeState for the provider.
+func getState(providerName string, req *http.Request) (string, error) {
+	if session, _ := Store.Get(req, stateSessionName); session != nil {
+		if value, err := getSessionValue(session, providerName); err == nil {
+			return value, nil
+		}
+	}
+	return GetFromSession(providerName, req)
+}
+
+// checkStateExpiry returns ErrStateExpired when the state stored for the
+// provider has expired. States stored without an expiry never expire.
+func checkStateExpiry(req *http.Request, providerName string) error {
+	session, _ := Store.Get(req, stateSessionName)
+	if session == nil {
+		return nil
+	}
+	expiresAt, ok := session.Values[providerName+stateExpirySuffix].(int64)
+	if ok && time.Now().Unix() >= expiresAt {
+		return ErrStateExpired
+	}
+	return nil
+}
+
 // GetFromSession retrieves a previously-stored value from the session.
 // If no value has previously been stored at the specified key, it will return an error.
 func GetFromSession(key string, req *http.Request) (string, error) {
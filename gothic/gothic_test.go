@@ -3,6 +3,9 @@ package gothic_test
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
 	"io/ioutil"
@@ -11,6 +14,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/sessions"
 	"github.com/markbates/goth"
@@ -94,6 +98,58 @@ func Test_BeginAuthHandler(t *testing.T) {
 		fmt.Sprintf(`<a href="%s">Temporary Redirect</a>`, html.EscapeString(au)))
 }
 
+func Test_ErrorHandler(t *testing.T) {
+	a := assert.New(t)
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth", nil)
+	a.NoError(err)
+
+	BeginAuthHandler(res, req)
+	a.Equal(http.StatusBadRequest, res.Code)
+
+	defer func() { ErrorHandler = nil }()
+	var handled error
+	ErrorHandler = func(res http.ResponseWriter, req *http.Request, err error) {
+		handled = err
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintf(res, `{"error":%q}`, err.Error())
+	}
+
+	res = httptest.NewRecorder()
+	BeginAuthHandler(res, req)
+	a.Error(handled)
+	a.Equal(http.StatusUnauthorized, res.Code)
+	a.Equal("application/json", res.Header().Get("Content-Type"))
+	a.Contains(res.Body.String(), `"error":`)
+}
+
+func Test_UnknownProvider(t *testing.T) {
+	a := assert.New(t)
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=unknown", nil)
+	a.NoError(err)
+
+	BeginAuthHandler(res, req)
+	a.Equal(http.StatusNotFound, res.Code)
+
+	defer func() { ErrorHandler = nil }()
+	var handled error
+	ErrorHandler = func(res http.ResponseWriter, req *http.Request, err error) {
+		handled = err
+	}
+	BeginAuthHandler(httptest.NewRecorder(), req)
+	var ue *ErrUnknownProvider
+	a.True(errors.As(handled, &ue))
+	a.Equal("unknown", ue.Name)
+
+	_, err = CompleteUserAuth(httptest.NewRecorder(), req)
+	a.True(errors.As(err, &ue))
+	a.Equal("unknown", ue.Name)
+}
+
 func Test_GetAuthURL(t *testing.T) {
 	a := assert.New(t)
 
@@ -125,6 +181,77 @@ func Test_GetAuthURL(t *testing.T) {
 	a.NotEqual(parsed.Query().Get("state"), parsed2.Query().Get("state"))
 }
 
+func Test_GetAuthURLWithState(t *testing.T) {
+	a := assert.New(t)
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=faux", nil)
+	a.NoError(err)
+
+	u, state, err := GetAuthURLWithState(res, req)
+	a.NoError(err)
+	a.NotEmpty(state)
+
+	parsed, err := url.Parse(u)
+	a.NoError(err)
+	a.Equal(state, parsed.Query().Get("state"))
+
+	// the state must still be stored so the callback can validate it
+	value, err := GetFromSession("faux", req)
+	a.NoError(err)
+	sess, err := fauxProvider.UnmarshalSession(value)
+	a.NoError(err)
+	au, _ := sess.GetAuthURL()
+	a.Equal(u, au)
+}
+
+func Test_StateTTL(t *testing.T) {
+	a := assert.New(t)
+	defer func(ttl time.Duration) { StateTTL = ttl }(StateTTL)
+	StateTTL = time.Hour
+	// cookies are needed to see the MaxAge of the sessions
+	RestoreKeys(t)
+	SetKeys([]byte("secret"))
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=faux", nil)
+	a.NoError(err)
+	_, state, err := GetAuthURLWithState(res, req)
+	a.NoError(err)
+
+	// the state has a cookie of its own expiring with StateTTL
+	cookies := res.Result().Cookies()
+	a.Len(cookies, 1)
+	a.Equal(SessionName+"_state", cookies[0].Name)
+	a.Equal(3600, cookies[0].MaxAge)
+
+	callback := func() (*httptest.ResponseRecorder, *http.Request) {
+		req, err := http.NewRequest("GET", "/auth/callback?provider=faux&state="+url.QueryEscape(state), nil)
+		a.NoError(err)
+		req.AddCookie(cookies[0])
+		return httptest.NewRecorder(), req
+	}
+
+	res, req = callback()
+	user, err := CompleteUserAuth(res, req)
+	a.NoError(err)
+	a.Equal("faux", user.Provider)
+	// the state session is deleted along with the gothic session
+	deleted := false
+	for _, c := range res.Result().Cookies() {
+		if c.Name == SessionName+"_state" {
+			deleted = c.MaxAge < 0
+		}
+	}
+	a.True(deleted)
+
+	res, req = callback()
+	session, _ := Store.Get(req, SessionName+"_state")
+	session.Values["faux_expires_at"] = time.Now().Add(-time.Second).Unix()
+	_, err = CompleteUserAuth(res, req)
+	a.ErrorIs(err, ErrStateExpired)
+}
+
 func Test_CompleteUserAuth(t *testing.T) {
 	a := assert.New(t)
 
@@ -145,6 +272,85 @@ func Test_CompleteUserAuth(t *testing.T) {
 	a.Equal(user.Email, "homer@example.com")
 }
 
+func Test_CompleteAuthTokensOnly(t *testing.T) {
+	a := assert.New(t)
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	a.NoError(err)
+
+	sess := faux.Session{Name: "Homer Simpson", Email: "homer@example.com"}
+	session, _ := Store.Get(req, SessionName)
+	session.Values["faux"] = gzipString(sess.Marshal())
+	err = session.Save(req, res)
+	a.NoError(err)
+
+	user, authorized, err := CompleteAuthTokensOnly(res, req)
+	a.NoError(err)
+
+	a.Equal("faux", user.Provider)
+	a.Equal("access", user.AccessToken)
+	// the user information has not been fetched
+	a.Empty(user.Name)
+	a.Equal("access", authorized.(*faux.Session).AccessToken)
+
+	provider, err := goth.GetProvider("faux")
+	a.NoError(err)
+	user, err = provider.FetchUser(authorized)
+	a.NoError(err)
+	a.Equal("Homer Simpson", user.Name)
+}
+
+func Test_ProviderFromPath(t *testing.T) {
+	a := assert.New(t)
+
+	req, err := http.NewRequest("GET", "/auth/faux/callback?state=abc", nil)
+	a.NoError(err)
+
+	provider, err := ProviderFromPath(req, "/auth/{provider}/callback")
+	a.NoError(err)
+	a.Equal("faux", provider)
+
+	provider, err = ProviderFromPath(req, "/{section}/{provider}/callback")
+	a.NoError(err)
+	a.Equal("faux", provider)
+
+	_, err = ProviderFromPath(req, "/auth/{provider}")
+	a.Error(err)
+
+	_, err = ProviderFromPath(req, "/login/{provider}/callback")
+	a.Error(err)
+}
+
+func Test_PathProviderHandlers(t *testing.T) {
+	a := assert.New(t)
+	// the handlers derive a new request, use cookies to carry the session
+	RestoreKeys(t)
+	SetKeys([]byte("secret"))
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/faux", nil)
+	a.NoError(err)
+
+	BeginAuthHandlerFor("faux").ServeHTTP(res, req)
+	a.Equal(http.StatusTemporaryRedirect, res.Code)
+
+	res = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/auth/faux/callback", nil)
+	a.NoError(err)
+	sess := faux.Session{Name: "Homer Simpson", Email: "homer@example.com"}
+	a.NoError(StoreInSession("faux", sess.Marshal(), req, res))
+
+	// the provider given explicitly wins over the query parameter
+	req, err = http.NewRequest("GET", "/auth/faux/callback?provider=unknown", nil)
+	a.NoError(err)
+	req.Header.Set("Cookie", res.Header().Get("Set-Cookie"))
+
+	user, err := CompleteUserAuthFor(httptest.NewRecorder(), req, "faux")
+	a.NoError(err)
+	a.Equal("Homer Simpson", user.Name)
+}
+
 func Test_CompleteUserAuthWithSessionDeducedProvider(t *testing.T) {
 	a := assert.New(t)
 
@@ -166,6 +372,58 @@ func Test_CompleteUserAuthWithSessionDeducedProvider(t *testing.T) {
 	a.Equal(user.Email, "homer@example.com")
 }
 
+func Test_CompleteUserAuthProviderError(t *testing.T) {
+	a := assert.New(t)
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/callback?provider=faux&error=access_denied&error_description=The+user+denied+access", nil)
+	a.NoError(err)
+
+	sess := faux.Session{Name: "Homer Simpson", AccessToken: "access"}
+	session, _ := Store.Get(req, SessionName)
+	session.Values["faux"] = gzipString(sess.Marshal())
+	err = session.Save(req, res)
+	a.NoError(err)
+
+	_, err = CompleteUserAuth(res, req)
+	var pe *ProviderAuthError
+	a.True(errors.As(err, &pe))
+	a.Equal("faux", pe.Provider)
+	a.Equal("access_denied", pe.Code)
+	a.Equal("The user denied access", pe.Description)
+	a.Equal(http.StatusUnauthorized, pe.StatusCode())
+	a.Contains(err.Error(), "The user denied access")
+}
+
+func Test_CompleteUserAuthSilentAuthErrors(t *testing.T) {
+	a := assert.New(t)
+
+	sentinels := []error{ErrLoginRequired, ErrConsentRequired, ErrAccountSelectionRequired, ErrInteractionRequired}
+	for code, want := range map[string]error{
+		"login_required":             ErrLoginRequired,
+		"consent_required":           ErrConsentRequired,
+		"account_selection_required": ErrAccountSelectionRequired,
+		"interaction_required":       ErrInteractionRequired,
+		"access_denied":              nil,
+	} {
+		res := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/auth/callback?provider=faux&error="+code, nil)
+		a.NoError(err)
+
+		sess := faux.Session{Name: "Homer Simpson", AccessToken: "access"}
+		session, _ := Store.Get(req, SessionName)
+		session.Values["faux"] = gzipString(sess.Marshal())
+		a.NoError(session.Save(req, res))
+
+		_, err = CompleteUserAuth(res, req)
+		var pe *ProviderAuthError
+		a.True(errors.As(err, &pe), code)
+		for _, sentinel := range sentinels {
+			a.Equal(sentinel == want, errors.Is(err, sentinel), code)
+		}
+	}
+}
+
 func Test_CompleteUserAuthWithContextParamProvider(t *testing.T) {
 	a := assert.New(t)
 
@@ -188,6 +446,99 @@ func Test_CompleteUserAuthWithContextParamProvider(t *testing.T) {
 	a.Equal(user.Email, "homer@example.com")
 }
 
+func Test_JSONCallbackHandler(t *testing.T) {
+	a := assert.New(t)
+	defer func() { JSONUserMarshaler = nil }()
+
+	callback := func(handler http.Handler) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/auth/faux/callback?provider=faux", nil)
+		a.NoError(err)
+
+		sess := faux.Session{Name: "Homer Simpson", Email: "homer@example.com"}
+		session, _ := Store.Get(req, SessionName)
+		session.Values["faux"] = gzipString(sess.Marshal())
+		a.NoError(session.Save(req, res))
+
+		handler.ServeHTTP(res, req)
+		return res
+	}
+
+	res := callback(JSONCallbackHandler(false))
+	a.Equal(http.StatusOK, res.Code)
+	a.Equal("application/json; charset=utf-8", res.Header().Get("Content-Type"))
+	a.Equal("no-store", res.Header().Get("Cache-Control"))
+	var user goth.User
+	a.NoError(json.Unmarshal(res.Body.Bytes(), &user))
+	a.Equal("Homer Simpson", user.Name)
+	a.Equal("access", user.AccessToken)
+
+	res = callback(JSONCallbackHandler(true))
+	a.NoError(json.Unmarshal(res.Body.Bytes(), &user))
+	a.Equal("<redacted>", user.AccessToken)
+
+	JSONUserMarshaler = func(user goth.User) (interface{}, error) {
+		return map[string]string{"name": user.Name, "token": user.AccessToken}, nil
+	}
+	res = callback(JSONCallbackHandler(true))
+	a.JSONEq(`{"name":"Homer Simpson","token":"<redacted>"}`, res.Body.String())
+
+	// a failed authentication goes to the error handler
+	res = httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/faux/callback?provider=faux", nil)
+	a.NoError(err)
+	JSONCallbackHandler(false).ServeHTTP(res, req)
+	a.Equal(http.StatusBadRequest, res.Code)
+}
+
+func Test_Middleware(t *testing.T) {
+	a := assert.New(t)
+
+	var (
+		user   goth.User
+		ok     bool
+		called bool
+	)
+	handler := Middleware(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		called = true
+		user, ok = UserFromContext(req.Context())
+	}))
+
+	// requests other than the callback are passed through
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/profile", nil)
+	a.NoError(err)
+	handler.ServeHTTP(res, req)
+	a.True(called)
+	a.False(ok)
+
+	called = false
+	res = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/auth/faux/callback?provider=faux", nil)
+	a.NoError(err)
+
+	sess := faux.Session{Name: "Homer Simpson", Email: "homer@example.com"}
+	session, _ := Store.Get(req, SessionName)
+	session.Values["faux"] = gzipString(sess.Marshal())
+	err = session.Save(req, res)
+	a.NoError(err)
+
+	handler.ServeHTTP(res, req)
+	a.True(called)
+	a.True(ok)
+	a.Equal("Homer Simpson", user.Name)
+	a.Equal("homer@example.com", user.Email)
+
+	// a failed authentication does not reach the next handler
+	called = false
+	res = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/auth/faux/callback?provider=faux", nil)
+	a.NoError(err)
+	handler.ServeHTTP(res, req)
+	a.False(called)
+	a.Equal(http.StatusBadRequest, res.Code)
+}
+
 func Test_Logout(t *testing.T) {
 	a := assert.New(t)
 
@@ -213,6 +564,144 @@ func Test_Logout(t *testing.T) {
 	a.Equal(session.Options.MaxAge, -1)
 }
 
+// memoryStore keeps sessions server-side, identified by the session cookie,
+// and deletes them when they are saved with a negative MaxAge.
+type memoryStore struct {
+	records map[string]map[interface{}]interface{}
+}
+
+func (m *memoryStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return m.New(r, name)
+}
+
+func (m *memoryStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	s := sessions.NewSession(m, name)
+	s.Options = &sessions.Options{Path: "/", MaxAge: 86400}
+	if c, err := r.Cookie(name); err == nil {
+		if values, ok := m.records[c.Value]; ok {
+			s.ID = c.Value
+			s.Values = values
+			s.IsNew = false
+		}
+	}
+	return s, nil
+}
+
+func (m *memoryStore) Save(r *http.Request, w http.ResponseWriter, s *sessions.Session) error {
+	if s.Options.MaxAge < 0 {
+		delete(m.records, s.ID)
+		http.SetCookie(w, sessions.NewCookie(s.Name(), "", s.Options))
+		return nil
+	}
+	if s.ID == "" {
+		s.ID = fmt.Sprintf("session-%d", len(m.records)+1)
+	}
+	m.records[s.ID] = s.Values
+	http.SetCookie(w, sessions.NewCookie(s.Name(), s.ID, s.Options))
+	return nil
+}
+
+func Test_LogoutDeletesServerSideSession(t *testing.T) {
+	a := assert.New(t)
+	defer func(store sessions.Store) { Store = store }(Store)
+	store := &memoryStore{records: map[string]map[interface{}]interface{}{}}
+	Store = store
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	a.NoError(err)
+	a.NoError(StoreInSession("faux", "value", req, res))
+	a.Len(store.records, 1)
+
+	req, err = http.NewRequest("GET", "/logout", nil)
+	a.NoError(err)
+	req.Header.Set("Cookie", res.Header().Get("Set-Cookie"))
+	value, err := GetFromSession("faux", req)
+	a.NoError(err)
+	a.Equal("value", value)
+
+	res = httptest.NewRecorder()
+	a.NoError(Logout(res, req))
+	a.Empty(store.records)
+	a.Contains(res.Header().Get("Set-Cookie"), "Max-Age=0")
+
+	_, err = GetFromSession("faux", req)
+	a.Error(err)
+}
+
+func Test_SetKeys(t *testing.T) {
+	a := assert.New(t)
+	RestoreKeys(t)
+
+	oldKey := []byte("old-secret")
+	newKey := []byte("new-secret")
+
+	SetKeys(oldKey)
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	a.NoError(err)
+	a.NoError(StoreInSession("faux", "value", req, res))
+	cookie := res.Header().Get("Set-Cookie")
+
+	read := func() (string, error) {
+		req, err := http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+		a.NoError(err)
+		req.Header.Set("Cookie", cookie)
+		return GetFromSession("faux", req)
+	}
+
+	// sessions signed with the old key are still accepted during rotation
+	SetKeys(newKey, oldKey)
+	value, err := read()
+	a.NoError(err)
+	a.Equal("value", value)
+
+	// ...but not once the old key has been dropped
+	SetKeys(newKey)
+	_, err = read()
+	a.Error(err)
+}
+
+func Test_SetCookieOptions(t *testing.T) {
+	a := assert.New(t)
+	RestoreKeys(t)
+
+	SetKeys([]byte("secret"))
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=faux", nil)
+	a.NoError(err)
+	a.NoError(StoreInSession("faux", "value", req, res))
+	a.Contains(res.Header().Get("Set-Cookie"), "SameSite=Lax")
+
+	SetCookieOptions(sessions.Options{
+		Path:     "/",
+		Domain:   "example.com",
+		MaxAge:   3600,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	defer SetCookieOptions(sessions.Options{Path: "/", MaxAge: 86400 * 30, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+
+	res = httptest.NewRecorder()
+	a.NoError(StoreInSession("faux", "value", req, res))
+	cookie := res.Header().Get("Set-Cookie")
+	a.Contains(cookie, "Domain=example.com")
+	a.Contains(cookie, "Max-Age=3600")
+	a.Contains(cookie, "Secure")
+	a.Contains(cookie, "SameSite=Lax")
+
+	// the signature checks of new keys use MaxAge too: with a negative
+	// MaxAge every cookie is already expired
+	SetCookieOptions(sessions.Options{Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	SetKeys([]byte("new-secret"), []byte("secret"))
+	res = httptest.NewRecorder()
+	a.NoError(StoreInSession("faux", "value", req, res))
+	req.Header.Set("Cookie", res.Header().Get("Set-Cookie"))
+	_, err = GetFromSession("faux", req)
+	a.Error(err)
+}
+
 func Test_SetState(t *testing.T) {
 	a := assert.New(t)
 
@@ -261,6 +750,98 @@ func Test_AppleStateValidation(t *testing.T) {
 	a.Equal(appleStateValue, GetState(req))
 }
 
+type slowProvider struct {
+	faux.Provider
+	delay  time.Duration
+	panics bool
+}
+
+func (p *slowProvider) Name() string {
+	return "slow"
+}
+
+func (p *slowProvider) BeginAuthContext(ctx context.Context, state string) (goth.Session, error) {
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if p.panics {
+		panic("provider bug")
+	}
+	return p.Provider.BeginAuth(state)
+}
+
+func (p *slowProvider) FetchUserContext(ctx context.Context, session goth.Session) (goth.User, error) {
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return goth.User{}, ctx.Err()
+	}
+	return p.Provider.FetchUser(session)
+}
+
+func Test_CompleteUserAuthTimeout(t *testing.T) {
+	a := assert.New(t)
+
+	goth.UseProviders(&slowProvider{delay: 100 * time.Millisecond})
+	defer func(timeout time.Duration) { Timeout = timeout }(Timeout)
+	Timeout = 10 * time.Millisecond
+
+	complete := func() (goth.User, error) {
+		res := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/auth/callback?provider=slow", nil)
+		a.NoError(err)
+		sess := faux.Session{Name: "Homer Simpson", AccessToken: "access"}
+		session, _ := Store.Get(req, SessionName)
+		session.Values["slow"] = gzipString(sess.Marshal())
+		a.NoError(session.Save(req, res))
+		return CompleteUserAuth(res, req)
+	}
+
+	_, err := complete()
+	var te *TimeoutError
+	a.ErrorAs(err, &te)
+	a.Equal("FetchUser", te.Op)
+
+	Timeout = time.Second
+	user, err := complete()
+	a.NoError(err)
+	a.Equal("Homer Simpson", user.Name)
+}
+
+func Test_BeginAuthHandlerTimeout(t *testing.T) {
+	a := assert.New(t)
+
+	goth.UseProviders(&slowProvider{delay: 100 * time.Millisecond})
+	defer func(timeout time.Duration) { Timeout = timeout }(Timeout)
+	Timeout = 10 * time.Millisecond
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=slow", nil)
+	a.NoError(err)
+
+	_, err = GetAuthURL(res, req)
+	var te *TimeoutError
+	a.ErrorAs(err, &te)
+	a.Equal("slow", te.Provider)
+	a.Equal(http.StatusServiceUnavailable, te.StatusCode())
+
+	res = httptest.NewRecorder()
+	BeginAuthHandler(res, req)
+	a.Equal(http.StatusServiceUnavailable, res.Code)
+
+	Timeout = time.Second
+	res = httptest.NewRecorder()
+	BeginAuthHandler(res, req)
+	a.Equal(http.StatusTemporaryRedirect, res.Code)
+
+	// a panicking provider fails the request, not the process
+	goth.UseProviders(&slowProvider{panics: true})
+	_, err = GetAuthURL(httptest.NewRecorder(), req)
+	a.ErrorContains(err, "provider bug")
+}
+
 func gzipString(value string) string {
 	var b bytes.Buffer
 	gz := gzip.NewWriter(&b)
@@ -290,3 +871,145 @@ func ungzipString(value string) string {
 
 	return string(s)
 }
+
+type promptProvider struct {
+	faux.Provider
+}
+
+func (p *promptProvider) Name() string {
+	return "prompt"
+}
+
+func (p *promptProvider) BeginAuthWithPrompt(state, prompt string) (goth.Session, error) {
+	sess, err := p.Provider.BeginAuth(state)
+	if err != nil {
+		return nil, err
+	}
+	sess.(*faux.Session).AuthURL += "&prompt=" + prompt
+	return sess, nil
+}
+
+func Test_SelectAccountOnce(t *testing.T) {
+	a := assert.New(t)
+	RestoreKeys(t)
+	SetKeys([]byte("secret"))
+	goth.UseProviders(&promptProvider{})
+	defer SetSelectAccountOnce("", 0)
+
+	// off by default
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=prompt", nil)
+	a.NoError(err)
+	authURL, err := GetAuthURL(res, req)
+	a.NoError(err)
+	a.NotContains(authURL, "prompt=")
+
+	SetSelectAccountOnce(DefaultSeenCookieName, time.Hour)
+	res = httptest.NewRecorder()
+	authURL, state, err := GetAuthURLWithState(res, req)
+	a.NoError(err)
+	a.Contains(authURL, "prompt=select_account")
+
+	req, err = http.NewRequest("GET", "/auth/callback?provider=prompt&state="+url.QueryEscape(state), nil)
+	a.NoError(err)
+	req.Header.Set("Cookie", res.Header().Get("Set-Cookie"))
+	res = httptest.NewRecorder()
+	_, err = CompleteUserAuth(res, req)
+	a.NoError(err)
+	var seen *http.Cookie
+	for _, c := range res.Result().Cookies() {
+		if c.Name == DefaultSeenCookieName {
+			seen = c
+		}
+	}
+	if !a.NotNil(seen) {
+		return
+	}
+	a.Equal(3600, seen.MaxAge)
+
+	req, err = http.NewRequest("GET", "/auth?provider=prompt", nil)
+	a.NoError(err)
+	req.AddCookie(&http.Cookie{Name: seen.Name, Value: seen.Value})
+	authURL, err = GetAuthURL(httptest.NewRecorder(), req)
+	a.NoError(err)
+	a.NotContains(authURL, "prompt=")
+
+	// a cookie signed with another key is ignored
+	req, err = http.NewRequest("GET", "/auth?provider=prompt", nil)
+	a.NoError(err)
+	req.AddCookie(&http.Cookie{Name: seen.Name, Value: "9999999999.AAAA"})
+	authURL, err = GetAuthURL(httptest.NewRecorder(), req)
+	a.NoError(err)
+	a.Contains(authURL, "prompt=select_account")
+}
+
+func Test_EncodeState(t *testing.T) {
+	a := assert.New(t)
+	RestoreKeys(t)
+	SetKeys([]byte("secret"))
+
+	state, err := EncodeState("faux", "csrf.token=")
+	a.NoError(err)
+	provider, csrf, err := DecodeState(state)
+	a.NoError(err)
+	a.Equal("faux", provider)
+	a.Equal("csrf.token=", csrf)
+
+	// tampering with the provider invalidates the signature
+	forged, err := EncodeState("other", "csrf.token=")
+	a.NoError(err)
+	_, _, err = DecodeState(forged[:strings.LastIndex(forged, ".")] + state[strings.LastIndex(state, "."):])
+	a.ErrorIs(err, ErrInvalidState)
+	_, _, err = DecodeState("not-a-state")
+	a.ErrorIs(err, ErrInvalidState)
+
+	// the provider is found from the state on the callback
+	req, err := http.NewRequest("GET", "/auth/callback?state="+url.QueryEscape(state), nil)
+	a.NoError(err)
+	name, err := GetProviderName(req)
+	a.NoError(err)
+	a.Equal("faux", name)
+
+	// states signed with an old key are still accepted after a rotation
+	SetKeys([]byte("new-secret"), []byte("secret"))
+	_, _, err = DecodeState(state)
+	a.NoError(err)
+	SetKeys([]byte("new-secret"))
+	_, _, err = DecodeState(state)
+	a.ErrorIs(err, ErrInvalidState)
+
+	// without a key anyone could sign a state
+	SetKeys(nil)
+	_, err = EncodeState("faux", "csrf.token=")
+	a.ErrorIs(err, ErrNoStateKey)
+}
+
+type contextProvider struct {
+	faux.Provider
+	hasDeadline bool
+}
+
+func (p *contextProvider) Name() string {
+	return "context"
+}
+
+func (p *contextProvider) BeginAuthContext(ctx context.Context, state string) (goth.Session, error) {
+	_, p.hasDeadline = ctx.Deadline()
+	return p.Provider.BeginAuth(state)
+}
+
+func Test_GetAuthURLContext(t *testing.T) {
+	a := assert.New(t)
+
+	provider := &contextProvider{}
+	goth.UseProviders(provider)
+	defer func(timeout time.Duration) { Timeout = timeout }(Timeout)
+	Timeout = time.Second
+
+	req, err := http.NewRequest("GET", "/auth?provider=context", nil)
+	a.NoError(err)
+	_, err = GetAuthURL(httptest.NewRecorder(), req)
+	a.NoError(err)
+	// the context given to the provider ends with Timeout
+	a.True(provider.hasDeadline)
+}
@@ -0,0 +1,57 @@
+package gothic
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/markbates/goth"
+)
+
+// JSONUserMarshaler builds the value written as JSON by JSONCallbackHandler,
+// to give the response the shape the front-end expects. When nil, the
+// goth.User itself is written.
+var JSONUserMarshaler func(user goth.User) (interface{}, error)
+
+/*
+JSONCallbackHandler completes the authentication process like
+CompleteUserAuth, but instead of leaving the response to the application,
+writes the user as JSON with a 200 status, as wanted by single page and
+mobile applications that call the callback URL themselves.
+
+With redactTokens, the tokens of the user are replaced by "<redacted>" and
+dropped from RawData, see goth.User.Redacted, so that they never reach the
+client; other secrets a provider may keep in RawData are not. The shape of the
+response can be changed with JSONUserMarshaler. If the authentication fails,
+the error is passed to ErrorHandler.
+*/
+func JSONCallbackHandler(redactTokens bool) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		user, err := CompleteUserAuth(res, req)
+		if err != nil {
+			handleError(res, req, err)
+			return
+		}
+		if redactTokens {
+			user = user.Redacted()
+		}
+
+		var v interface{} = user
+		if JSONUserMarshaler != nil {
+			if v, err = JSONUserMarshaler(user); err != nil {
+				handleError(res, req, err)
+				return
+			}
+		}
+		body, err := json.Marshal(v)
+		if err != nil {
+			handleError(res, req, err)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		// the body may hold tokens
+		res.Header().Set("Cache-Control", "no-store")
+		res.WriteHeader(http.StatusOK)
+		res.Write(body)
+	})
+}
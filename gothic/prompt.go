@@ -0,0 +1,81 @@
+package gothic
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markbates/goth"
+)
+
+// PromptProvider is implemented by providers that can override the prompt
+// parameter of a single auth request, see SetSelectAccountOnce.
+type PromptProvider interface {
+	BeginAuthWithPrompt(state, prompt string) (goth.Session, error)
+}
+
+// DefaultSeenCookieName is the cookie name suggested for SetSelectAccountOnce.
+const DefaultSeenCookieName = "_gothic_seen"
+
+var (
+	seenCookieName   string
+	seenCookieMaxAge time.Duration
+)
+
+/*
+SetSelectAccountOnce makes GetAuthURL ask providers implementing
+PromptProvider to show their account chooser (prompt=select_account) only to
+browsers that never completed a login before. After a successful
+CompleteUserAuth, a cookie with the given name, signed with the keys given to
+SetKeys, remembers the browser for maxAge; later logins leave the prompt to
+the provider's defaults, so returning users sign in silently.
+
+	gothic.SetSelectAccountOnce(gothic.DefaultSeenCookieName, 365*24*time.Hour)
+
+An empty cookie name turns the feature off, which is the default.
+*/
+func SetSelectAccountOnce(cookieName string, maxAge time.Duration) {
+	seenCookieName = cookieName
+	seenCookieMaxAge = maxAge
+}
+
+// seenBefore reports whether the request carries a valid seen cookie. It
+// returns true when SetSelectAccountOnce is off, so the prompt is untouched.
+func seenBefore(req *http.Request) bool {
+	if seenCookieName == "" {
+		return true
+	}
+	c, err := req.Cookie(seenCookieName)
+	if err != nil {
+		return false
+	}
+	expiry, mac, ok := strings.Cut(c.Value, ".")
+	if !ok {
+		return false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(mac)
+	if err != nil || !validSignature(sig, "seen:"+seenCookieName, expiry) {
+		return false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	return err == nil && time.Now().Unix() < unix
+}
+
+// markSeen sets the seen cookie when SetSelectAccountOnce is on.
+func markSeen(res http.ResponseWriter, req *http.Request) {
+	if seenCookieName == "" || len(signingKeys) == 0 || len(signingKeys[0]) == 0 {
+		return
+	}
+	expiry := strconv.FormatInt(time.Now().Add(seenCookieMaxAge).Unix(), 10)
+	http.SetCookie(res, &http.Cookie{
+		Name:     seenCookieName,
+		Value:    expiry + "." + base64.RawURLEncoding.EncodeToString(signature(signingKeys[0], "seen:"+seenCookieName, expiry)),
+		Path:     "/",
+		MaxAge:   int(seenCookieMaxAge / time.Second),
+		Secure:   req.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,92 @@
+package gothic
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidState is returned by DecodeState when the state was not made by
+// EncodeState with one of the keys given to SetKeys, or was tampered with.
+var ErrInvalidState = errors.New("gothic: invalid state")
+
+// ErrNoStateKey is returned by EncodeState when no key was given to SetKeys,
+// either directly or through the SESSION_SECRET environment variable.
+var ErrNoStateKey = errors.New("gothic: no key is set to sign the state")
+
+/*
+EncodeState returns a state carrying both the provider name and a CSRF token,
+signed with the current key given to SetKeys. When used as the state, a
+single callback route serving several providers finds out which one started
+the flow without a separate query parameter, as GetProviderName decodes it:
+
+	gothic.SetState = func(req *http.Request) string {
+		provider, _ := gothic.GetProviderName(req)
+		state, err := gothic.EncodeState(provider, csrfToken(req))
+		if err != nil {
+			panic(err)
+		}
+		return state
+	}
+
+The state is signed, not encrypted: do not put secrets in it. ErrNoStateKey
+is returned when no key is set, as anyone could forge the state.
+*/
+func EncodeState(provider, csrf string) (string, error) {
+	if len(signingKeys) == 0 || len(signingKeys[0]) == 0 {
+		return "", ErrNoStateKey
+	}
+	enc := base64.RawURLEncoding
+	data := enc.EncodeToString([]byte(provider)) + "." + enc.EncodeToString([]byte(csrf))
+	return data + "." + enc.EncodeToString(signature(signingKeys[0], "state", data)), nil
+}
+
+// DecodeState returns the provider name and CSRF token of a state made by
+// EncodeState, or ErrInvalidState when its signature does not match any of
+// the keys given to SetKeys.
+func DecodeState(state string) (provider, csrf string, err error) {
+	i := strings.LastIndexByte(state, '.')
+	if i < 0 {
+		return "", "", ErrInvalidState
+	}
+	data, mac := state[:i], state[i+1:]
+	sig, err := base64.RawURLEncoding.DecodeString(mac)
+	if err != nil || !validSignature(sig, "state", data) {
+		return "", "", ErrInvalidState
+	}
+
+	parts := strings.Split(data, ".")
+	if len(parts) != 2 {
+		return "", "", ErrInvalidState
+	}
+	p, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", "", ErrInvalidState
+	}
+	c, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", "", ErrInvalidState
+	}
+	return string(p), string(c), nil
+}
+
+// signature is the HMAC of data for the given purpose, so that a value
+// signed for one purpose is never accepted for another.
+func signature(key []byte, purpose, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose + "|" + data))
+	return mac.Sum(nil)
+}
+
+// validSignature reports whether sig was made by signature with one of the
+// keys given to SetKeys. Empty keys are never trusted.
+func validSignature(sig []byte, purpose, data string) bool {
+	for _, key := range signingKeys {
+		if len(key) > 0 && hmac.Equal(sig, signature(key, purpose, data)) {
+			return true
+		}
+	}
+	return false
+}
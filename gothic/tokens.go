@@ -0,0 +1,65 @@
+package gothic
+
+import (
+	"net/http"
+
+	"github.com/markbates/goth"
+)
+
+/*
+CompleteAuthTokensOnly completes the authentication process like
+CompleteUserAuth, but skips fetching the user information from the provider.
+This saves a round trip for applications that only store the tokens and load
+the profile later, with provider.FetchUser and the returned session.
+
+The returned user only holds the provider name and the tokens of the
+session, when it implements goth.TokenSession. Otherwise, only the session
+is returned, along with an empty user.
+*/
+func CompleteAuthTokensOnly(res http.ResponseWriter, req *http.Request) (goth.User, goth.Session, error) {
+	providerName, err := GetProviderName(req)
+	if err != nil {
+		return goth.User{}, nil, err
+	}
+
+	provider, err := getProvider(providerName)
+	if err != nil {
+		return goth.User{}, nil, err
+	}
+
+	value, err := getState(providerName, req)
+	if err != nil {
+		return goth.User{}, nil, err
+	}
+	defer Logout(res, req)
+	sess, params, err := checkCallback(req, providerName, provider, value)
+	if err != nil {
+		return goth.User{}, nil, err
+	}
+
+	err = authorize(req, providerName, provider, sess, params)
+	if err != nil {
+		return goth.User{}, nil, err
+	}
+
+	markSeen(res, req)
+	return tokenUser(providerName, sess), sess, nil
+}
+
+// tokenUser returns a user holding the tokens of sess, or an empty user when
+// sess does not implement goth.TokenSession.
+func tokenUser(providerName string, sess goth.Session) goth.User {
+	ts, ok := sess.(goth.TokenSession)
+	if !ok {
+		return goth.User{}
+	}
+	tokens := ts.SessionTokens()
+	return goth.User{
+		Provider:          providerName,
+		AccessToken:       tokens.AccessToken,
+		AccessTokenSecret: tokens.AccessTokenSecret,
+		RefreshToken:      tokens.RefreshToken,
+		IDToken:           tokens.IDToken,
+		ExpiresAt:         tokens.ExpiresAt,
+	}
+}
@@ -0,0 +1,85 @@
+package goth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrIntrospectionNotSupported is returned by TokenIntrospector
+// implementations that have no introspection endpoint configured.
+var ErrIntrospectionNotSupported = errors.New("provider has no token introspection endpoint")
+
+// IntrospectionResult is the response of an OAuth 2.0 token introspection
+// request. See https://datatracker.ietf.org/doc/html/rfc7662#section-2.2
+type IntrospectionResult struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Nbf       int64  `json:"nbf,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+
+	// RawData holds every member of the response, including "aud" and any
+	// provider specific extensions.
+	RawData map[string]interface{} `json:"-"`
+}
+
+// TokenIntrospector is implemented by providers that support RFC 7662 token
+// introspection. It lets applications validate opaque access tokens without
+// a userinfo call.
+type TokenIntrospector interface {
+	Introspect(ctx context.Context, token string) (*IntrospectionResult, error)
+}
+
+// IntrospectToken performs an RFC 7662 introspection request against the
+// given endpoint, authenticating with the client credentials. It is meant to
+// be used by providers implementing TokenIntrospector.
+func IntrospectToken(ctx context.Context, client *http.Client, endpoint, clientID, secret, token string) (*IntrospectionResult, error) {
+	if endpoint == "" {
+		return nil, ErrIntrospectionNotSupported
+	}
+
+	form := url.Values{"token": {token}}
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(secret))
+
+	resp, err := HTTPClientWithFallBack(client).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspection endpoint responded with a %d", resp.StatusCode)
+	}
+
+	body, err := ReadAllLimited(resp.Body, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &IntrospectionResult{}
+	if err := json.Unmarshal(body, result); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, &result.RawData); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
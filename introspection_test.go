@@ -0,0 +1,37 @@
+package goth_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_IntrospectToken(t *testing.T) {
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		a.True(ok)
+		a.Equal("client", user)
+		a.Equal("secret", pass)
+		a.Equal("1234567890", r.FormValue("token"))
+		fmt.Fprint(w, `{"active":true,"scope":"openid email","sub":"homer","exp":1700000000,"aud":["a","b"]}`)
+	}))
+	defer ts.Close()
+
+	result, err := goth.IntrospectToken(context.Background(), nil, ts.URL, "client", "secret", "1234567890")
+	a.NoError(err)
+	a.True(result.Active)
+	a.Equal("openid email", result.Scope)
+	a.Equal("homer", result.Sub)
+	a.Equal(int64(1700000000), result.Exp)
+	a.Equal([]interface{}{"a", "b"}, result.RawData["aud"])
+
+	_, err = goth.IntrospectToken(context.Background(), nil, "", "client", "secret", "1234567890")
+	a.ErrorIs(err, goth.ErrIntrospectionNotSupported)
+}
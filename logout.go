@@ -0,0 +1,44 @@
+package goth
+
+import (
+	"errors"
+	"net/url"
+)
+
+// ErrLogoutNotSupported is returned by LogoutURLBuilder implementations when
+// the provider has no end session endpoint.
+var ErrLogoutNotSupported = errors.New("provider has no end session endpoint")
+
+// LogoutURLBuilder is implemented by providers that support OpenID Connect
+// RP-initiated logout. Redirecting the user to the returned URL ends their
+// session with the provider, in addition to the local session.
+type LogoutURLBuilder interface {
+	LogoutURL(idToken, postLogoutRedirect string) (string, error)
+}
+
+// EndSessionURL builds the RP-initiated logout URL for the given end session
+// endpoint, see https://openid.net/specs/openid-connect-rpinitiated-1_0.html.
+// Empty parameters are left out. It is meant to be used by providers
+// implementing LogoutURLBuilder.
+func EndSessionURL(endpoint, clientID, idToken, postLogoutRedirect string) (string, error) {
+	if endpoint == "" {
+		return "", ErrLogoutNotSupported
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	if idToken != "" {
+		q.Set("id_token_hint", idToken)
+	}
+	if postLogoutRedirect != "" {
+		q.Set("post_logout_redirect_uri", postLogoutRedirect)
+	}
+	if clientID != "" {
+		q.Set("client_id", clientID)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
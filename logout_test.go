@@ -0,0 +1,23 @@
+package goth_test
+
+import (
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EndSessionURL(t *testing.T) {
+	a := assert.New(t)
+
+	u, err := goth.EndSessionURL("https://idp.example.com/logout?tenant=1", "client", "id-token", "https://app.example.com/")
+	a.NoError(err)
+	a.Equal("https://idp.example.com/logout?client_id=client&id_token_hint=id-token&post_logout_redirect_uri=https%3A%2F%2Fapp.example.com%2F&tenant=1", u)
+
+	u, err = goth.EndSessionURL("https://idp.example.com/logout", "", "", "")
+	a.NoError(err)
+	a.Equal("https://idp.example.com/logout", u)
+
+	_, err = goth.EndSessionURL("", "client", "id-token", "")
+	a.Equal(goth.ErrLogoutNotSupported, err)
+}
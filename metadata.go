@@ -0,0 +1,110 @@
+package goth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MetadataCache stores provider metadata such as OpenID Connect discovery
+// documents and JWKS key sets, so that providers talking to the same issuer
+// share a single copy. Implementations must be safe for concurrent use.
+// Implement it to share the documents between processes, e.g. with Redis.
+type MetadataCache interface {
+	// Get returns the document stored for url, if it has not expired.
+	Get(url string) ([]byte, bool)
+	// Set stores the document for url for at most ttl.
+	Set(url string, data []byte, ttl time.Duration)
+	// Delete removes the document stored for url.
+	Delete(url string)
+}
+
+// DefaultMetadataCache is consulted by providers fetching discovery documents
+// and key sets. It defaults to an in-memory cache shared by the whole process.
+// Set it to nil to always fetch the documents.
+var DefaultMetadataCache MetadataCache = NewMemoryMetadataCache()
+
+// MetadataTTL is how long fetched metadata documents are cached.
+var MetadataTTL = time.Hour
+
+// MemoryMetadataCache is a process-local MetadataCache.
+type MemoryMetadataCache struct {
+	mu      sync.RWMutex
+	entries map[string]metadataEntry
+}
+
+type metadataEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemoryMetadataCache returns an empty MemoryMetadataCache.
+func NewMemoryMetadataCache() *MemoryMetadataCache {
+	return &MemoryMetadataCache{entries: map[string]metadataEntry{}}
+}
+
+// Get returns the document stored for url, if it has not expired.
+func (c *MemoryMetadataCache) Get(url string) ([]byte, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.entries[url]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.data, true
+}
+
+// Set stores the document for url for at most ttl.
+func (c *MemoryMetadataCache) Set(url string, data []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[url] = metadataEntry{data: data, expires: time.Now().Add(ttl)}
+}
+
+// Delete removes the document stored for url.
+func (c *MemoryMetadataCache) Delete(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, url)
+}
+
+// FetchMetadata returns the document at url, using DefaultMetadataCache when
+// it is set. Only successful responses are cached.
+func FetchMetadata(client *http.Client, url string) ([]byte, error) {
+	return FetchMetadataContext(context.Background(), client, url)
+}
+
+// FetchMetadataContext is FetchMetadata with a context bounding the request.
+func FetchMetadataContext(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	cache := DefaultMetadataCache
+	if cache != nil {
+		if data, ok := cache.Get(url); ok {
+			return data, nil
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("Non-success code for metadata URL %s: %d", url, res.StatusCode)
+	}
+
+	data, err := ReadAllLimited(res.Body, DefaultMaxResponseSize)
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		cache.Set(url, data, MetadataTTL)
+	}
+	return data, nil
+}
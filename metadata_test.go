@@ -0,0 +1,64 @@
+package goth_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FetchMetadata(t *testing.T) {
+	a := assert.New(t)
+
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, `{"issuer":"https://example.com"}`)
+	}))
+	defer ts.Close()
+
+	for i := 0; i < 3; i++ {
+		data, err := goth.FetchMetadata(ts.Client(), ts.URL+"/.well-known/openid-configuration")
+		a.NoError(err)
+		a.Equal(`{"issuer":"https://example.com"}`, string(data))
+	}
+	a.Equal(1, calls)
+
+	goth.DefaultMetadataCache.Delete(ts.URL + "/.well-known/openid-configuration")
+	_, err := goth.FetchMetadata(ts.Client(), ts.URL+"/.well-known/openid-configuration")
+	a.NoError(err)
+	a.Equal(2, calls)
+}
+
+func Test_FetchMetadata_Error(t *testing.T) {
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	_, err := goth.FetchMetadata(ts.Client(), ts.URL)
+	a.Error(err)
+	_, ok := goth.DefaultMetadataCache.Get(ts.URL)
+	a.False(ok)
+}
+
+func Test_MemoryMetadataCache_Expiry(t *testing.T) {
+	a := assert.New(t)
+
+	c := goth.NewMemoryMetadataCache()
+	c.Set("fresh", []byte("a"), time.Hour)
+	c.Set("stale", []byte("b"), -time.Second)
+
+	data, ok := c.Get("fresh")
+	a.True(ok)
+	a.Equal("a", string(data))
+
+	_, ok = c.Get("stale")
+	a.False(ok)
+}
@@ -0,0 +1,24 @@
+package goth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+)
+
+// ErrNonceMismatch is returned by providers verifying the nonce of OpenID
+// Connect ID tokens when the nonce claim does not match the one sent in the
+// auth request, which means the ID token was not issued for this login.
+var ErrNonceMismatch = errors.New("nonce in ID token does not match the auth request")
+
+// NewNonce returns a random value to send as the nonce of an OpenID Connect
+// auth request, see
+// https://openid.net/specs/openid-connect-core-1_0.html#NonceNotes
+func NewNonce() string {
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		panic("goth: source of randomness unavailable: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,16 @@
+package goth_test
+
+import (
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewNonce(t *testing.T) {
+	a := assert.New(t)
+
+	nonce := goth.NewNonce()
+	a.Len(nonce, 43)
+	a.NotEqual(nonce, goth.NewNonce())
+}
@@ -2,8 +2,15 @@ package goth
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"sync"
+	"time"
 
 	"golang.org/x/oauth2"
 )
@@ -21,31 +28,122 @@ type Provider interface {
 	RefreshTokenAvailable() bool                             // Refresh token is provided by auth provider or not
 }
 
+// ContextBeginner is implemented by providers that can stop beginning the
+// authentication process once ctx is done, e.g. when BeginAuth has to fetch
+// discovery metadata.
+type ContextBeginner interface {
+	BeginAuthContext(ctx context.Context, state string) (Session, error)
+}
+
+// BeginAuthContext begins the authentication process with provider, using
+// its BeginAuthContext method when it implements ContextBeginner and falling
+// back to BeginAuth otherwise.
+func BeginAuthContext(ctx context.Context, provider Provider, state string) (Session, error) {
+	if cb, ok := provider.(ContextBeginner); ok {
+		return cb.BeginAuthContext(ctx, state)
+	}
+	return provider.BeginAuth(state)
+}
+
+// ContextRefresher is implemented by providers whose token refresh stops
+// once ctx is done.
+type ContextRefresher interface {
+	RefreshTokenContext(ctx context.Context, refreshToken string) (*oauth2.Token, error)
+}
+
+// RefreshTokenContext refreshes the token with provider, using its
+// RefreshTokenContext method when it implements ContextRefresher and falling
+// back to RefreshToken otherwise, in which case ctx is ignored.
+func RefreshTokenContext(ctx context.Context, provider Provider, refreshToken string) (*oauth2.Token, error) {
+	if cr, ok := provider.(ContextRefresher); ok {
+		return cr.RefreshTokenContext(ctx, refreshToken)
+	}
+	return provider.RefreshToken(refreshToken)
+}
+
+// ContextFetcher is implemented by providers whose FetchUser stops once ctx
+// is done.
+type ContextFetcher interface {
+	FetchUserContext(ctx context.Context, session Session) (User, error)
+}
+
+// FetchUserContext fetches the user of session with provider, using its
+// FetchUserContext method when it implements ContextFetcher and falling back
+// to FetchUser otherwise, in which case ctx is ignored.
+func FetchUserContext(ctx context.Context, provider Provider, session Session) (User, error) {
+	if cf, ok := provider.(ContextFetcher); ok {
+		return cf.FetchUserContext(ctx, session)
+	}
+	return provider.FetchUser(session)
+}
+
 const NoAuthUrlErrorMessage = "an AuthURL has not been set"
 
 // Providers is list of known/available providers.
 type Providers map[string]Provider
 
-var providers = Providers{}
+var (
+	providersMu sync.RWMutex
+	providers   = Providers{}
+)
 
 // UseProviders adds a list of available providers for use with Goth.
 // Can be called multiple times. If you pass the same provider more
 // than once, the last will be used.
 func UseProviders(viders ...Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
 	for _, provider := range viders {
 		providers[provider.Name()] = provider
 	}
 }
 
-// GetProviders returns a list of all the providers currently in use.
+// ErrProviderExists is returned by RegisterProvider when another provider is
+// already registered under the same name.
+var ErrProviderExists = errors.New("goth: a provider is already registered under this name")
+
+// RegisterProvider adds provider for use with Goth, e.g. from the init
+// function of the package configuring it. Registering the same provider
+// again does nothing, but it fails with ErrProviderExists if another
+// provider is already registered under its name: use ReplaceProvider to
+// swap it.
+func RegisterProvider(provider Provider) error {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	name := provider.Name()
+	if existing, ok := providers[name]; ok {
+		if existing == provider {
+			return nil
+		}
+		return fmt.Errorf("%w: %s", ErrProviderExists, name)
+	}
+	providers[name] = provider
+	return nil
+}
+
+// ReplaceProvider makes provider the one used for its name, replacing any
+// provider registered under that name.
+func ReplaceProvider(provider Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[provider.Name()] = provider
+}
+
+// GetProviders returns a list of all the providers currently in use. The
+// map is shared with Goth: do not read it while providers may be registered
+// concurrently.
 func GetProviders() Providers {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
 	return providers
 }
 
 // GetProvider returns a previously created provider. If Goth has not
 // been told to use the named provider it will return an error.
 func GetProvider(name string) (Provider, error) {
+	providersMu.RLock()
 	provider := providers[name]
+	providersMu.RUnlock()
 	if provider == nil {
 		return nil, fmt.Errorf("no provider for %s exists", name)
 	}
@@ -55,21 +153,206 @@ func GetProvider(name string) (Provider, error) {
 // ClearProviders will remove all providers currently in use.
 // This is useful, mostly, for testing purposes.
 func ClearProviders() {
+	providersMu.Lock()
+	defer providersMu.Unlock()
 	providers = Providers{}
 }
 
-// ContextForClient provides a context for use with oauth2.
+// ContextForClient provides a context for use with oauth2. Prefer
+// ContextWithClient when a context is at hand, so that its cancellation
+// reaches the requests.
 func ContextForClient(h *http.Client) context.Context {
+	return ContextWithClient(oauth2.NoContext, h)
+}
+
+// ContextWithClient returns a copy of ctx making oauth2 send its requests
+// with h.
+func ContextWithClient(ctx context.Context, h *http.Client) context.Context {
 	if h == nil {
-		return oauth2.NoContext
+		return ctx
+	}
+	return context.WithValue(ctx, oauth2.HTTPClient, h)
+}
+
+// Token endpoint authentication methods, see
+// https://openid.net/specs/openid-connect-core-1_0.html#ClientAuthentication
+const (
+	// TokenEndpointAuthBasic sends the client credentials with HTTP Basic
+	// authentication.
+	TokenEndpointAuthBasic = "client_secret_basic"
+	// TokenEndpointAuthPost sends the client credentials in the request body.
+	TokenEndpointAuthPost = "client_secret_post"
+)
+
+// TokenEndpointAuthStyle returns the oauth2.AuthStyle sending the client
+// credentials as method says: TokenEndpointAuthBasic or "basic", or
+// TokenEndpointAuthPost or "post". An empty method lets oauth2 try both.
+func TokenEndpointAuthStyle(method string) (oauth2.AuthStyle, error) {
+	switch method {
+	case "":
+		return oauth2.AuthStyleAutoDetect, nil
+	case "basic", TokenEndpointAuthBasic:
+		return oauth2.AuthStyleInHeader, nil
+	case "post", TokenEndpointAuthPost:
+		return oauth2.AuthStyleInParams, nil
 	}
-	return context.WithValue(oauth2.NoContext, oauth2.HTTPClient, h)
+	return oauth2.AuthStyleAutoDetect, fmt.Errorf("unsupported token endpoint auth method %q", method)
 }
 
 // HTTPClientWithFallBack to be used in all fetch operations.
+// When h is nil, the fallback client is returned: http.DefaultClient, unless
+// SetFallbackTransport has been called.
 func HTTPClientWithFallBack(h *http.Client) *http.Client {
 	if h != nil {
 		return h
 	}
-	return http.DefaultClient
+	fallbackMu.RLock()
+	defer fallbackMu.RUnlock()
+	return fallbackClient
+}
+
+// UserAgentClient returns HTTPClientWithFallBack(h), setting the User-Agent
+// header given to SetUserAgent on the requests that have none. Providers
+// return it from their Client method, so that all their requests carry it.
+func UserAgentClient(h *http.Client) *http.Client {
+	fallbackMu.RLock()
+	ua := userAgent
+	fallbackMu.RUnlock()
+	return WithUserAgent(HTTPClientWithFallBack(h), ua)
+}
+
+var (
+	fallbackMu     sync.RWMutex
+	fallbackClient = http.DefaultClient
+	userAgent      = DefaultUserAgent
+)
+
+// DefaultUserAgent is the User-Agent header sent by providers until
+// SetUserAgent is called.
+const DefaultUserAgent = "goth (+https://github.com/markbates/goth)"
+
+// SetUserAgent sets the User-Agent header sent with the requests of every
+// provider, through UserAgentClient, e.g. to identify the application to APIs
+// that reject Go's default one, like Reddit. Requests that already carry a
+// User-Agent, set by a provider or the transport of its HTTPClient, keep it.
+// An empty string sends Go's default User-Agent. Providers may override it
+// with a SetUserAgent method of their own.
+func SetUserAgent(ua string) {
+	fallbackMu.Lock()
+	defer fallbackMu.Unlock()
+	userAgent = ua
+}
+
+// WithUserAgent returns a copy of client setting the User-Agent header of the
+// requests that have none to ua. client is returned as is when ua is empty.
+func WithUserAgent(client *http.Client, ua string) *http.Client {
+	if ua == "" {
+		return client
+	}
+	c := *client
+	c.Transport = &userAgentTransport{base: client.Transport, userAgent: ua}
+	return &c
+}
+
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Header.Get("User-Agent") != "" {
+		return base.RoundTrip(req)
+	}
+	// a RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return base.RoundTrip(req)
+}
+
+// TransportOptions tunes the transport of the fallback client, see
+// SetFallbackTransport. Zero values keep the defaults of http.DefaultTransport.
+type TransportOptions struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	// HTTP/2 is used by default when the server supports it.
+	DisableHTTP2 bool
+}
+
+// SetFallbackTransport replaces the fallback client used by every provider
+// that has no HTTPClient of its own with a client using a transport tuned
+// with opts. This is global to the process and meant to be called once at
+// startup, e.g. by services refreshing many tokens that want to keep more
+// idle connections to the providers.
+func SetFallbackTransport(opts TransportOptions) {
+	t := defaultTransport()
+	if opts.MaxIdleConns > 0 {
+		t.MaxIdleConns = opts.MaxIdleConns
+	}
+	if opts.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	}
+	if opts.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = opts.IdleConnTimeout
+	}
+	if opts.DisableHTTP2 {
+		t.ForceAttemptHTTP2 = false
+		// a non-nil empty map disables HTTP/2
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	fallbackMu.Lock()
+	defer fallbackMu.Unlock()
+	fallbackClient = &http.Client{Transport: t}
+}
+
+// defaultTransport returns a copy of http.DefaultTransport, or a transport
+// with the same settings when it has been replaced, e.g. by instrumentation
+// or test mocks.
+func defaultTransport() *http.Transport {
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		return t.Clone()
+	}
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// DefaultMaxResponseSize is the default limit, in bytes, applied by providers
+// when reading responses from user information endpoints.
+const DefaultMaxResponseSize int64 = 1 << 20
+
+// ErrResponseTooLarge is returned by ReadAllLimited when the response exceeds
+// the allowed size.
+var ErrResponseTooLarge = errors.New("response body exceeds the maximum allowed size")
+
+// ReadAllLimited reads r until EOF like ioutil.ReadAll, but fails with
+// ErrResponseTooLarge instead of reading more than limit bytes. A limit of
+// zero or less means DefaultMaxResponseSize. This guards providers against
+// misbehaving endpoints returning huge bodies.
+func ReadAllLimited(r io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		limit = DefaultMaxResponseSize
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, ErrResponseTooLarge
+	}
+	return b, nil
 }
@@ -1,11 +1,17 @@
 package goth_test
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/faux"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
 )
 
 func Test_UseProviders(t *testing.T) {
@@ -33,3 +39,173 @@ func Test_GetProvider(t *testing.T) {
 	a.Equal(err.Error(), "no provider for unknown exists")
 	goth.ClearProviders()
 }
+
+type namedProvider struct {
+	faux.Provider
+	name string
+}
+
+func (p *namedProvider) Name() string {
+	return p.name
+}
+
+func Test_RegisterProvider(t *testing.T) {
+	a := assert.New(t)
+	defer goth.ClearProviders()
+
+	first := &faux.Provider{}
+	a.NoError(goth.RegisterProvider(first))
+	a.NoError(goth.RegisterProvider(first))
+	other := &namedProvider{name: "other"}
+	a.NoError(goth.RegisterProvider(other))
+	a.Len(goth.GetProviders(), 2)
+
+	second := &faux.Provider{}
+	a.ErrorIs(goth.RegisterProvider(second), goth.ErrProviderExists)
+	p, err := goth.GetProvider("faux")
+	a.NoError(err)
+	a.Same(first, p)
+
+	goth.ReplaceProvider(second)
+	p, err = goth.GetProvider("faux")
+	a.NoError(err)
+	a.Same(second, p)
+	a.Len(goth.GetProviders(), 2)
+}
+
+func Test_RegisterProviderConcurrently(t *testing.T) {
+	a := assert.New(t)
+	defer goth.ClearProviders()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p := &namedProvider{name: fmt.Sprintf("faux-%d", i)}
+			a.NoError(goth.RegisterProvider(p))
+			goth.ReplaceProvider(p)
+			_, err := goth.GetProvider(p.Name())
+			a.NoError(err)
+		}(i)
+	}
+	wg.Wait()
+	a.Len(goth.GetProviders(), 10)
+}
+
+func Test_ReadAllLimited(t *testing.T) {
+	a := assert.New(t)
+
+	b, err := goth.ReadAllLimited(strings.NewReader("12345"), 5)
+	a.NoError(err)
+	a.Equal("12345", string(b))
+
+	_, err = goth.ReadAllLimited(strings.NewReader("123456"), 5)
+	a.ErrorIs(err, goth.ErrResponseTooLarge)
+
+	_, err = goth.ReadAllLimited(strings.NewReader(strings.Repeat("a", int(goth.DefaultMaxResponseSize)+1)), 0)
+	a.ErrorIs(err, goth.ErrResponseTooLarge)
+}
+
+type contextProvider struct {
+	faux.Provider
+	ctx context.Context
+}
+
+func (p *contextProvider) BeginAuthContext(ctx context.Context, state string) (goth.Session, error) {
+	p.ctx = ctx
+	return p.BeginAuth(state)
+}
+
+func Test_BeginAuthContext(t *testing.T) {
+	a := assert.New(t)
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	provider := &contextProvider{}
+	_, err := goth.BeginAuthContext(ctx, provider, "state")
+	a.NoError(err)
+	a.Equal(ctx, provider.ctx)
+
+	sess, err := goth.BeginAuthContext(ctx, &faux.Provider{}, "state")
+	a.NoError(err)
+	a.NotNil(sess)
+}
+
+func (p *contextProvider) RefreshTokenContext(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	p.ctx = ctx
+	return &oauth2.Token{AccessToken: "access"}, nil
+}
+
+func Test_RefreshTokenContext(t *testing.T) {
+	a := assert.New(t)
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	provider := &contextProvider{}
+	token, err := goth.RefreshTokenContext(ctx, provider, "refresh")
+	a.NoError(err)
+	a.Equal("access", token.AccessToken)
+	a.Equal(ctx, provider.ctx)
+
+	// faux has no context variant
+	_, err = goth.RefreshTokenContext(ctx, &faux.Provider{}, "refresh")
+	a.NoError(err)
+}
+
+func (p *contextProvider) FetchUserContext(ctx context.Context, session goth.Session) (goth.User, error) {
+	p.ctx = ctx
+	return goth.User{UserID: "1"}, nil
+}
+
+func Test_FetchUserContext(t *testing.T) {
+	a := assert.New(t)
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	provider := &contextProvider{}
+	user, err := goth.FetchUserContext(ctx, provider, &faux.Session{})
+	a.NoError(err)
+	a.Equal("1", user.UserID)
+	a.Equal(ctx, provider.ctx)
+
+	// faux has no context variant
+	user, err = goth.FetchUserContext(ctx, &faux.Provider{}, &faux.Session{Name: "Homer", AccessToken: "access"})
+	a.NoError(err)
+	a.Equal("Homer", user.Name)
+}
+
+func Test_ContextWithClient(t *testing.T) {
+	a := assert.New(t)
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+	a.Equal(ctx, goth.ContextWithClient(ctx, nil))
+
+	client := &http.Client{}
+	withClient := goth.ContextWithClient(ctx, client)
+	a.Equal(client, withClient.Value(oauth2.HTTPClient))
+	a.Equal("value", withClient.Value(ctxKey{}))
+}
+
+func Test_TokenEndpointAuthStyle(t *testing.T) {
+	a := assert.New(t)
+
+	for method, want := range map[string]oauth2.AuthStyle{
+		"":                          oauth2.AuthStyleAutoDetect,
+		"basic":                     oauth2.AuthStyleInHeader,
+		goth.TokenEndpointAuthBasic: oauth2.AuthStyleInHeader,
+		"post":                      oauth2.AuthStyleInParams,
+		goth.TokenEndpointAuthPost:  oauth2.AuthStyleInParams,
+	} {
+		style, err := goth.TokenEndpointAuthStyle(method)
+		a.NoError(err)
+		a.Equal(want, style, method)
+	}
+
+	_, err := goth.TokenEndpointAuthStyle("private_key_jwt")
+	a.Error(err)
+}
@@ -46,7 +46,7 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 }
 
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Name is the name used to retrieve this provider later.
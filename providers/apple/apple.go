@@ -159,7 +159,7 @@ func (p Provider) FetchUser(session goth.Session) (goth.User, error) {
 func (Provider) Debug(bool) {}
 
 func (p Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.httpClient)
+	return goth.UserAgentClient(p.httpClient)
 }
 
 func (p Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
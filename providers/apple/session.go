@@ -104,14 +104,10 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 			}
 
 			// get the public key for verifying the identity token signature
-			set, err := jwk.Fetch(context.Background(), idTokenVerificationKeyEndpoint, jwk.WithHTTPClient(p.Client()))
+			selectedKey, err := lookupKey(p, kid)
 			if err != nil {
 				return nil, err
 			}
-			selectedKey, found := set.LookupKeyID(kid)
-			if !found {
-				return nil, errors.New("could not find matching public key")
-			}
 			pubKey := &rsa.PublicKey{}
 			err = selectedKey.Raw(pubKey)
 			if err != nil {
@@ -137,6 +133,29 @@ func (s Session) String() string {
 	return s.Marshal()
 }
 
+// lookupKey returns Apple's public key with the given key id. The key set is
+// shared through goth.DefaultMetadataCache and fetched again when the key is
+// not found, as Apple may have rotated its keys.
+func lookupKey(p *Provider, kid string) (jwk.Key, error) {
+	for attempt := 0; ; attempt++ {
+		data, err := goth.FetchMetadata(p.Client(), idTokenVerificationKeyEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		set, err := jwk.Parse(data)
+		if err == nil {
+			if key, found := set.LookupKeyID(kid); found {
+				return key, nil
+			}
+			err = errors.New("could not find matching public key")
+		}
+		if attempt > 0 || goth.DefaultMetadataCache == nil {
+			return nil, err
+		}
+		goth.DefaultMetadataCache.Delete(idTokenVerificationKeyEndpoint)
+	}
+}
+
 // BoolString is a type that can be unmarshalled from a JSON field that can be either a boolean or a string.
 // It is used to unmarshal some fields in the Apple ID token that can be sent as either boolean or string.
 // See https://developer.apple.com/documentation/sign_in_with_apple/sign_in_with_apple_rest_api/authenticating_users_with_sign_in_with_apple#3383773
@@ -1,12 +1,17 @@
 package apple
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/json"
+	"net/http"
 	"testing"
 
+	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/testsupport"
 )
 
 func Test_Implements_Session(t *testing.T) {
@@ -96,3 +101,59 @@ func TestIDTokenClaimsUnmarshal(t *testing.T) {
 		})
 	}
 }
+
+// testKeySet returns a provider serving a key set with a single key of the
+// given id, and the number of times the key set has been fetched.
+func testKeySet(a *assert.Assertions, kid string) (*Provider, *int) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	pub, err := jwk.New(&key.PublicKey)
+	a.NoError(err)
+	a.NoError(pub.Set(jwk.KeyIDKey, kid))
+	keys, err := json.Marshal(map[string]interface{}{"keys": []jwk.Key{pub}})
+	a.NoError(err)
+
+	fetches := 0
+	client := testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal(idTokenVerificationKeyEndpoint, r.URL.String())
+		fetches++
+		w.Write(keys)
+	})
+	return New("client", "secret", "/foo", client), &fetches
+}
+
+// not parallel: the key set is cached process wide
+func Test_LookupKey(t *testing.T) {
+	a := assert.New(t)
+	// unique per run, so a key set cached by another test is fetched again
+	kid := goth.NewNonce()
+	p, fetches := testKeySet(a, kid)
+
+	key, err := lookupKey(p, kid)
+	a.NoError(err)
+	a.Equal(kid, key.KeyID())
+	a.Equal(1, *fetches)
+
+	// the key set is cached
+	key, err = lookupKey(p, kid)
+	a.NoError(err)
+	a.Equal(kid, key.KeyID())
+	a.Equal(1, *fetches)
+}
+
+// not parallel: the key set is cached process wide
+func Test_LookupKeyUnknown(t *testing.T) {
+	a := assert.New(t)
+	p, fetches := testKeySet(a, goth.NewNonce())
+
+	// cache the key set, then look up a key that is not in it
+	goth.DefaultMetadataCache.Delete(idTokenVerificationKeyEndpoint)
+	_, err := goth.FetchMetadata(p.Client(), idTokenVerificationKeyEndpoint)
+	a.NoError(err)
+	a.Equal(1, *fetches)
+
+	_, err = lookupKey(p, "unknown")
+	a.EqualError(err, "could not find matching public key")
+	// the key set is fetched again once in case Apple rotated its keys
+	a.Equal(2, *fetches)
+}
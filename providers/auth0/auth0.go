@@ -65,7 +65,7 @@ func (p *Provider) SetName(name string) {
 }
 
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the auth0 package.
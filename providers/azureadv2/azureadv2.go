@@ -6,6 +6,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
 
 	"github.com/markbates/goth"
 	"golang.org/x/oauth2"
@@ -58,6 +59,18 @@ const (
 	ConsumersTenant TenantType = "consumers"
 )
 
+// PersonalTenantID is the ID of the tenant holding all personal Microsoft
+// accounts.
+const PersonalTenantID = "9188040d-6c67-4c5b-b112-36a304b66dad"
+
+// Account types reported in RawData["account_type"] by FetchUser.
+const (
+	// PersonalAccount is a personal Microsoft account (MSA).
+	PersonalAccount = "personal"
+	// WorkAccount is a work or school account from Azure Active Directory.
+	WorkAccount = "work"
+)
+
 // New creates a new AzureAD provider, and sets up important connection details.
 // You should always call `AzureAD.New` to get a new Provider. Never try to create
 // one manually.
@@ -112,7 +125,7 @@ func (p *Provider) SetName(name string) {
 
 // Client is HTTP client to be used in all fetch operations.
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the package
@@ -161,7 +174,46 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	user.AccessToken = msSession.AccessToken
 	user.RefreshToken = msSession.RefreshToken
 	user.ExpiresAt = msSession.ExpiresAt
-	return user, err
+	user.IDToken = msSession.IDToken
+	if err != nil {
+		return user, err
+	}
+	return user, setAccountType(&user)
+}
+
+// setAccountType tells personal accounts from work or school accounts by the
+// tenant ID of the ID token, which the openid scope is needed to get. The
+// tenant ID is put in RawData["tid"], and the account type in
+// RawData["account_type"].
+func setAccountType(user *goth.User) error {
+	if user.IDToken == "" {
+		return nil
+	}
+	claims, raw, err := goth.ParseUnverifiedClaims(user.IDToken)
+	if err != nil {
+		return err
+	}
+	tid, _ := raw["tid"].(string)
+	if tid == "" {
+		// the issuer is https://login.microsoftonline.com/{tid}/v2.0
+		parts := strings.Split(claims.Issuer, "/")
+		if len(parts) >= 2 {
+			tid = parts[len(parts)-2]
+		}
+	}
+	if tid == "" {
+		return nil
+	}
+	if user.RawData == nil {
+		user.RawData = map[string]interface{}{}
+	}
+	user.RawData["tid"] = tid
+	if tid == PersonalTenantID {
+		user.RawData["account_type"] = PersonalAccount
+	} else {
+		user.RawData["account_type"] = WorkAccount
+	}
+	return nil
 }
 
 // RefreshTokenAvailable refresh token is provided by auth provider or not
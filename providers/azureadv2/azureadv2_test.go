@@ -1,10 +1,14 @@
 package azureadv2_test
 
 import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
 	"testing"
 
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/azureadv2"
+	"github.com/markbates/goth/testsupport"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -57,6 +61,37 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(s.AccessToken, "1234567890")
 }
 
+func Test_FetchUserAccountType(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := azureadProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("Bearer access", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1234","displayName":"Homer Simpson"}`)
+	})
+
+	for claims, accountType := range map[string]string{
+		`{"tid":"9188040d-6c67-4c5b-b112-36a304b66dad"}`:                                        azureadv2.PersonalAccount,
+		`{"tid":"72f988bf-86f1-41af-91ab-2d7cd011db47"}`:                                        azureadv2.WorkAccount,
+		`{"iss":"https://login.microsoftonline.com/72f988bf-86f1-41af-91ab-2d7cd011db47/v2.0"}`: azureadv2.WorkAccount,
+	} {
+		enc := base64.RawURLEncoding
+		idToken := enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString([]byte(claims)) + ".signature"
+		user, err := provider.FetchUser(&azureadv2.Session{AccessToken: "access", IDToken: idToken})
+		a.NoError(err)
+		a.Equal("1234", user.UserID)
+		a.Equal(accountType, user.RawData["account_type"])
+		a.NotEmpty(user.RawData["tid"])
+	}
+
+	// without the openid scope there is no ID token to tell
+	user, err := provider.FetchUser(&azureadv2.Session{AccessToken: "access"})
+	a.NoError(err)
+	a.NotContains(user.RawData, "account_type")
+}
+
 func azureadProvider() *azureadv2.Provider {
 	return azureadv2.New(applicationID, secret, redirectUri, azureadv2.ProviderOptions{})
 }
@@ -15,6 +15,7 @@ type Session struct {
 	AccessToken  string    `json:"at"`
 	RefreshToken string    `json:"rt"`
 	ExpiresAt    time.Time `json:"exp"`
+	IDToken      string    `json:"idt,omitempty"`
 }
 
 // GetAuthURL will return the URL set by calling the `BeginAuth` func
@@ -41,6 +42,7 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	s.AccessToken = token.AccessToken
 	s.RefreshToken = token.RefreshToken
 	s.ExpiresAt = token.Expiry
+	s.IDToken, _ = token.Extra("id_token").(string)
 
 	return token.AccessToken, err
 }
@@ -61,3 +63,13 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 	err := json.NewDecoder(strings.NewReader(data)).Decode(session)
 	return session, err
 }
+
+// SessionTokens implements goth.TokenSession.
+func (s Session) SessionTokens() goth.SessionTokens {
+	return goth.SessionTokens{
+		AccessToken:  s.AccessToken,
+		RefreshToken: s.RefreshToken,
+		IDToken:      s.IDToken,
+		ExpiresAt:    s.ExpiresAt,
+	}
+}
@@ -77,7 +77,7 @@ func (p *Provider) SetName(name string) {
 }
 
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the bitbucket package.
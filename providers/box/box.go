@@ -13,8 +13,8 @@ import (
 )
 
 const (
-	authURL         string = "https://app.box.com/api/oauth2/authorize"
-	tokenURL        string = "https://app.box.com/api/oauth2/token"
+	authURL         string = "https://account.box.com/api/oauth2/authorize"
+	tokenURL        string = "https://api.box.com/oauth2/token"
 	endpointProfile string = "https://api.box.com/2.0/users/me"
 )
 
@@ -53,7 +53,7 @@ func (p *Provider) SetName(name string) {
 }
 
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the box package.
@@ -138,6 +138,7 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	user.NickName = u.Name
 	user.UserID = u.ID
 	user.Location = u.Location
+	user.AvatarURL = u.AvatarURL
 	return nil
 }
 
@@ -146,7 +147,10 @@ func (p *Provider) RefreshTokenAvailable() bool {
 	return true
 }
 
-// RefreshToken get new access token based on the refresh token
+// RefreshToken get new access token based on the refresh token.
+// Box access tokens are short-lived (one hour) and Box rotates refresh tokens:
+// every refresh returns a new refresh token and invalidates the one that was
+// used, so the RefreshToken of the returned token must always be persisted.
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
 	token := &oauth2.Token{RefreshToken: refreshToken}
 	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
@@ -1,11 +1,14 @@
 package box_test
 
 import (
+	"fmt"
+	"net/http"
 	"os"
 	"testing"
 
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/box"
+	"github.com/markbates/goth/testsupport"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -32,7 +35,45 @@ func Test_BeginAuth(t *testing.T) {
 	session, err := p.BeginAuth("test_state")
 	s := session.(*box.Session)
 	a.NoError(err)
-	a.Contains(s.AuthURL, "app.box.com/api/oauth2/authorize")
+	a.Contains(s.AuthURL, "account.box.com/api/oauth2/authorize")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	p.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("api.box.com", r.URL.Host)
+		a.Equal("/2.0/users/me", r.URL.Path)
+		a.Equal("Bearer 1234567890", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"type":"user","id":"11446498","name":"Aaron Levie","login":"ceo@example.com","avatar_url":"https://www.box.com/api/avatar/large/181216415"}`)
+	})
+
+	session, err := p.UnmarshalSession(`{"AccessToken":"1234567890"}`)
+	a.NoError(err)
+	user, err := p.FetchUser(session)
+	a.NoError(err)
+	a.Equal("11446498", user.UserID)
+	a.Equal("Aaron Levie", user.Name)
+	a.Equal("ceo@example.com", user.Email)
+	a.Equal("https://www.box.com/api/avatar/large/181216415", user.AvatarURL)
+}
+
+func Test_RefreshTokenRotation(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	p.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("/oauth2/token", r.URL.Path)
+		a.Equal("old-refresh-token", r.FormValue("refresh_token"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"new-access-token","expires_in":3600,"refresh_token":"new-refresh-token","token_type":"bearer"}`)
+	})
+
+	token, err := p.RefreshToken("old-refresh-token")
+	a.NoError(err)
+	a.Equal("new-access-token", token.AccessToken)
+	a.Equal("new-refresh-token", token.RefreshToken)
 }
 
 func Test_SessionFromJSON(t *testing.T) {
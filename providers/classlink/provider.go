@@ -35,7 +35,7 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 }
 
 func (p Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 func (p Provider) Name() string {
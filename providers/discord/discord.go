@@ -82,7 +82,7 @@ func (p *Provider) SetPermissions(permissions string) {
 }
 
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is no-op for the Discord package.
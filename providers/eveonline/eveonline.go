@@ -55,7 +55,7 @@ func (p *Provider) SetName(name string) {
 
 // Client returns the default http.client
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the eveonline package.
@@ -73,7 +73,7 @@ func (p *Provider) SetCustomFields(fields []string) *Provider {
 }
 
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the facebook package.
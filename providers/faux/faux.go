@@ -76,7 +76,7 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 }
 
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is used only for testing.
@@ -108,3 +108,8 @@ func (s *Session) Marshal() string {
 func (s *Session) GetAuthURL() (string, error) {
 	return s.AuthURL, nil
 }
+
+// SessionTokens implements goth.TokenSession.
+func (s *Session) SessionTokens() goth.SessionTokens {
+	return goth.SessionTokens{AccessToken: s.AccessToken}
+}
@@ -0,0 +1,92 @@
+package github_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/markbates/goth/providers/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewApp(t *testing.T) {
+	a := assert.New(t)
+
+	_, err := github.NewApp("1234", []byte("not a key"))
+	a.Error(err)
+
+	key, keyPEM := appKey(a)
+	app, err := github.NewApp("1234", keyPEM)
+	a.NoError(err)
+	a.Equal("1234", app.AppID)
+	a.True(key.Equal(app.PrivateKey))
+}
+
+func Test_AppJWT(t *testing.T) {
+	a := assert.New(t)
+
+	key, keyPEM := appKey(a)
+	app, err := github.NewApp("1234", keyPEM)
+	a.NoError(err)
+
+	signed, err := app.JWT()
+	a.NoError(err)
+	claims := &jwt.RegisteredClaims{}
+	_, err = jwt.ParseWithClaims(signed, claims, func(*jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"RS256"}))
+	a.NoError(err)
+	a.Equal("1234", claims.Issuer)
+	a.True(claims.IssuedAt.Before(time.Now()))
+	a.LessOrEqual(claims.ExpiresAt.Sub(claims.IssuedAt.Time), 10*time.Minute)
+}
+
+func Test_AppInstallationToken(t *testing.T) {
+	a := assert.New(t)
+
+	key, keyPEM := appKey(a)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("POST", r.Method)
+		if r.URL.Path != "/app/installations/42/access_tokens" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, err := jwt.Parse(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), func(*jwt.Token) (interface{}, error) {
+			return &key.PublicKey, nil
+		})
+		a.NoError(err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"token":"ghs_installation","expires_at":"2030-01-01T00:00:00Z","permissions":{"contents":"read"}}`)
+	}))
+	defer ts.Close()
+
+	defer func(url string) { github.InstallationTokenURL = url }(github.InstallationTokenURL)
+	github.InstallationTokenURL = ts.URL + "/app/installations/%d/access_tokens"
+
+	app, err := github.NewApp("1234", keyPEM)
+	a.NoError(err)
+	token, err := app.InstallationToken(context.Background(), 42)
+	a.NoError(err)
+	a.Equal("ghs_installation", token.AccessToken)
+	a.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), token.Expiry)
+	a.Equal(map[string]interface{}{"contents": "read"}, token.Extra("permissions"))
+
+	_, err = app.InstallationToken(context.Background(), 7)
+	a.Error(err)
+}
+
+func appKey(a *assert.Assertions) (*rsa.PrivateKey, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	return key, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
@@ -26,11 +26,15 @@ import (
 //	github.TokenURL = "https://github.acme.com/login/oauth/access_token
 //	github.ProfileURL = "https://github.acme.com/api/v3/user
 //	github.EmailURL = "https://github.acme.com/api/v3/user/emails
+//	github.InstallationTokenURL = "https://github.acme.com/api/v3/app/installations/%d/access_tokens"
 var (
 	AuthURL    = "https://github.com/login/oauth/authorize"
 	TokenURL   = "https://github.com/login/oauth/access_token"
 	ProfileURL = "https://api.github.com/user"
 	EmailURL   = "https://api.github.com/user/emails"
+	// InstallationTokenURL is formatted with the installation ID, see
+	// App.InstallationToken.
+	InstallationTokenURL = "https://api.github.com/app/installations/%d/access_tokens"
 )
 
 var (
@@ -82,7 +86,7 @@ func (p *Provider) SetName(name string) {
 }
 
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the github package.
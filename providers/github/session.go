@@ -54,3 +54,8 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 	err := json.NewDecoder(strings.NewReader(data)).Decode(sess)
 	return sess, err
 }
+
+// SessionTokens implements goth.TokenSession.
+func (s Session) SessionTokens() goth.SessionTokens {
+	return goth.SessionTokens{AccessToken: s.AccessToken}
+}
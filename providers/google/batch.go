@@ -0,0 +1,70 @@
+package google
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/markbates/goth"
+)
+
+// FetchResult is the outcome of fetching one user with FetchUsers.
+type FetchResult struct {
+	User goth.User
+	// StatusCode is the HTTP status code of the userinfo request, see
+	// FetchUserWithResponse. A run of http.StatusTooManyRequests means the
+	// job should slow down.
+	StatusCode int
+	Err        error
+}
+
+// FetchUsers fetches the users of many sessions, e.g. to re-sync stored
+// profiles, with at most concurrency requests in flight. All requests go
+// through the provider's HTTP client, so connections are reused. The results
+// are in the order of sessions. Once ctx is done, the requests in flight are
+// cancelled and the sessions not fetched yet are skipped, their result
+// holding ctx.Err(). Once Google answers a request with a quota error, see
+// QuotaError, no more requests are sent and the result of the skipped
+// sessions holds ErrQuotaExceeded, so that the job can retry them later;
+// requests already in flight at that point complete normally.
+func (p *Provider) FetchUsers(ctx context.Context, sessions []goth.Session, concurrency int) []FetchResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]FetchResult, len(sessions))
+	slots := make(chan struct{}, concurrency)
+	quota := make(chan struct{})
+	var quotaOnce sync.Once
+	var wg sync.WaitGroup
+	for i, session := range sessions {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		case <-quota:
+		}
+		if err := ctx.Err(); err != nil {
+			results[i].Err = err
+			continue
+		}
+		select {
+		case <-quota:
+			results[i].Err = ErrQuotaExceeded
+			continue
+		default:
+		}
+		wg.Add(1)
+		go func(i int, session goth.Session) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			user, status, err := p.fetchUser(ctx, session)
+			results[i] = FetchResult{User: user, StatusCode: status, Err: err}
+			if errors.Is(err, ErrQuotaExceeded) {
+				quotaOnce.Do(func() { close(quota) })
+			}
+		}(i, session)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,94 @@
+package google
+
+import (
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/markbates/goth"
+)
+
+const endpointPeopleEmails string = "https://people.googleapis.com/v1/people/me?personFields=emailAddresses"
+
+func init() {
+	// RawData["emails"] must survive goth.User being stored in a session
+	gob.Register([]Email{})
+}
+
+// Email is an email address of the user, as listed in RawData["emails"].
+type Email struct {
+	Value    string `json:"value"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+type peopleEmails struct {
+	EmailAddresses []struct {
+		Metadata struct {
+			Primary  bool `json:"primary"`
+			Verified bool `json:"verified"`
+		} `json:"metadata"`
+		Value string `json:"value"`
+	} `json:"emailAddresses"`
+}
+
+// emails lists the email addresses of the user for RawData["emails"], taken
+// from the People API, which is only queried when ScopeEmailsRead has been
+// requested. When that request fails, the list only holds the email returned
+// by userinfo.
+func (p *Provider) emails(ctx context.Context, accessToken string, u googleUser) []Email {
+	if emails, err := p.fetchPeopleEmails(ctx, accessToken); err == nil && len(emails) > 0 {
+		return emails
+	}
+	if u.Email == "" {
+		return []Email{}
+	}
+	return []Email{{Value: u.Email, Primary: true, Verified: u.VerifiedEmail || u.EmailVerified}}
+}
+
+func (p *Provider) fetchPeopleEmails(ctx context.Context, accessToken string) ([]Email, error) {
+	req, err := http.NewRequest("GET", endpointPeopleEmails, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for name, value := range p.requestHeaders {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with a %d trying to fetch email addresses", p.providerName, response.StatusCode)
+	}
+
+	data, err := goth.ReadAllLimited(response.Body, p.maxResponseSize)
+	if err != nil {
+		return nil, err
+	}
+	var people peopleEmails
+	if err := json.Unmarshal(data, &people); err != nil {
+		return nil, err
+	}
+
+	emails := make([]Email, 0, len(people.EmailAddresses))
+	for _, e := range people.EmailAddresses {
+		emails = append(emails, Email{Value: e.Value, Primary: e.Metadata.Primary, Verified: e.Metadata.Verified})
+	}
+	return emails, nil
+}
+
+func (p *Provider) hasScope(scope string) bool {
+	for _, s := range p.config.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
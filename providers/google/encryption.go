@@ -0,0 +1,64 @@
+package google
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+)
+
+// encryptedSessionPrefix marks a marshaled session that has been encrypted.
+const encryptedSessionPrefix = "goth-enc:"
+
+// sessionCipher encrypts marshaled sessions with AES-GCM. The first AEAD is
+// the current key and is used for sealing; all of them are tried when opening
+// so that sessions sealed with a previous key keep working during rotation.
+type sessionCipher struct {
+	aeads []cipher.AEAD
+}
+
+func newSessionCipher(current []byte, old ...[]byte) (*sessionCipher, error) {
+	c := &sessionCipher{}
+	for _, key := range append([][]byte{current}, old...) {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		c.aeads = append(c.aeads, aead)
+	}
+	return c, nil
+}
+
+func (c *sessionCipher) seal(plaintext []byte) string {
+	aead := c.aeads[0]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		panic("google: source of randomness unavailable: " + err.Error())
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return encryptedSessionPrefix + base64.RawURLEncoding.EncodeToString(sealed)
+}
+
+func (c *sessionCipher) open(data string) ([]byte, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(data, encryptedSessionPrefix))
+	if err != nil {
+		return nil, err
+	}
+	for _, aead := range c.aeads {
+		if len(sealed) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		if plaintext, err := aead.Open(nil, nonce, ciphertext, nil); err == nil {
+			return plaintext, nil
+		}
+	}
+	return nil, errors.New("google: unable to decrypt session with any of the configured keys")
+}
@@ -0,0 +1,163 @@
+package google
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrRefreshTokenInvalid is matched by errors.Is when Google rejected a
+// refresh token with invalid_grant. Retrying will not help: the user has to
+// sign in again to get a new refresh token. Google invalidates refresh tokens
+// when:
+//   - the user revoked the application's access,
+//   - the token has not been used for six months,
+//   - the user changed their password and the token carries Gmail scopes,
+//   - the application has more than 100 live refresh tokens for the user,
+//     in which case the oldest one is invalidated,
+//   - the application's OAuth consent screen is in "Testing" mode, where
+//     tokens expire after seven days,
+//   - a Workspace administrator removed the user or restricted the
+//     application.
+//
+// See https://developers.google.com/identity/protocols/oauth2#expiration
+var ErrRefreshTokenInvalid = errors.New("google: refresh token is invalid or revoked")
+
+// ErrAuthCodeExpired is matched by errors.Is when Google rejected the
+// authorization code with invalid_grant during Session.Authorize. Codes are
+// valid for about ten minutes and can only be exchanged once, so this
+// usually means the user stayed too long on the consent screen, or the
+// callback was replayed. Restart the login with BeginAuth rather than asking
+// the user to grant access again.
+var ErrAuthCodeExpired = errors.New("google: authorization code is expired or already used")
+
+// invalidGrantError wraps the invalid_grant error returned by Google so that
+// it matches both its sentinel error and *oauth2.RetrieveError.
+type invalidGrantError struct {
+	sentinel error
+	err      error
+}
+
+func (e *invalidGrantError) Error() string {
+	return e.sentinel.Error() + ": " + e.err.Error()
+}
+
+func (e *invalidGrantError) Unwrap() error {
+	return e.err
+}
+
+func (e *invalidGrantError) Is(target error) bool {
+	return target == e.sentinel
+}
+
+// ErrScopesNotGranted is matched by errors.Is when the user did not grant all
+// the scopes given to SetRequiredScopes. Use errors.As with
+// *ScopesNotGrantedError to get the missing scopes.
+var ErrScopesNotGranted = errors.New("google: required scopes were not granted")
+
+// ScopesNotGrantedError is returned by FetchUser when the user did not grant
+// all the scopes given to SetRequiredScopes.
+type ScopesNotGrantedError struct {
+	Missing []string
+}
+
+func (e *ScopesNotGrantedError) Error() string {
+	return ErrScopesNotGranted.Error() + ": " + strings.Join(e.Missing, " ")
+}
+
+// Is reports whether target is ErrScopesNotGranted.
+func (e *ScopesNotGrantedError) Is(target error) bool {
+	return target == ErrScopesNotGranted
+}
+
+// ErrQuotaExceeded is matched by errors.Is when Google refused a request
+// because a quota or rate limit was hit. Use errors.As with *QuotaError to get
+// the suggested retry delay.
+var ErrQuotaExceeded = errors.New("google: quota exceeded")
+
+// QuotaError is returned by RefreshToken when Google rejected the refresh
+// because of a quota or rate limit, and by FetchUser when the userinfo
+// endpoint answered with http.StatusTooManyRequests.
+type QuotaError struct {
+	// RetryAfter is the delay requested by Google through the Retry-After
+	// header, or zero when none was given.
+	RetryAfter time.Duration
+	// Err is the underlying error, usually an *oauth2.RetrieveError.
+	Err error
+}
+
+func (e *QuotaError) Error() string {
+	msg := ErrQuotaExceeded.Error()
+	if e.RetryAfter > 0 {
+		msg += ", retry after " + e.RetryAfter.String()
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *QuotaError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrQuotaExceeded.
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// refreshError classifies errors of the token endpoint: invalid refresh tokens
+// match ErrRefreshTokenInvalid, exceeded quotas are turned into a *QuotaError
+// and any other error is returned unchanged.
+func refreshError(err error) error {
+	var re *oauth2.RetrieveError
+	if !errors.As(err, &re) {
+		return err
+	}
+	if re.ErrorCode == "invalid_grant" {
+		return &invalidGrantError{sentinel: ErrRefreshTokenInvalid, err: err}
+	}
+	code := strings.ToLower(re.ErrorCode)
+	quota := strings.Contains(code, "quota") || strings.Contains(code, "rate_limit")
+	if re.Response != nil && re.Response.StatusCode == http.StatusTooManyRequests {
+		quota = true
+	}
+	if !quota {
+		return err
+	}
+	qe := &QuotaError{Err: err}
+	if re.Response != nil {
+		qe.RetryAfter = parseRetryAfter(re.Response.Header.Get("Retry-After"), time.Now())
+	}
+	return qe
+}
+
+// exchangeError classifies errors of the authorization code exchange, where
+// invalid_grant means that the code expired rather than a revoked grant.
+func exchangeError(err error) error {
+	var re *oauth2.RetrieveError
+	if errors.As(err, &re) && re.ErrorCode == "invalid_grant" {
+		return &invalidGrantError{sentinel: ErrAuthCodeExpired, err: err}
+	}
+	return err
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an
+// HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -3,18 +3,33 @@
 package google
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/markbates/goth"
 	"golang.org/x/oauth2"
 )
 
-const endpointProfile string = "https://www.googleapis.com/oauth2/v2/userinfo"
+const (
+	endpointProfile string = "https://www.googleapis.com/oauth2/v2/userinfo"
+	endpointRevoke  string = "https://oauth2.googleapis.com/revoke"
+)
+
+// PKCE code challenge methods, see SetPKCEMethod.
+const (
+	PKCEMethodS256  = "S256"
+	PKCEMethodPlain = "plain"
+)
 
 // New creates a new Google provider, and sets up important connection details.
 // You should always call `google.New` to get a new Provider. Never try to create
@@ -26,6 +41,8 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 		CallbackURL:  callbackURL,
 		providerName: "google",
 
+		clockSkewLeeway: DefaultClockSkewLeeway,
+
 		// We can get a refresh token from Google by this option.
 		// See https://developers.google.com/identity/protocols/oauth2/openid-connect#access-type-param
 		authCodeOptions: []oauth2.AuthCodeOption{
@@ -36,15 +53,89 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 	return p
 }
 
+// NewAuthOnly creates a new Google provider for applications that only need to
+// know who the user is and never call Google APIs. It requests the "openid"
+// scope, plus any scope given (e.g. "email" or "profile" to get these claims),
+// and no refresh token. The access token returned by Google is discarded and
+// FetchUser reads the user from the ID token instead of calling the userinfo
+// endpoint, once its signature, issuer, expiry and audience have been
+// verified with Google's signing keys.
+func NewAuthOnly(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	all := []string{"openid"}
+	for _, scope := range scopes {
+		if scope != "openid" {
+			all = append(all, scope)
+		}
+	}
+	p := New(clientKey, secret, callbackURL, all...)
+	p.authOnly = true
+	p.authCodeOptions = nil
+	return p
+}
+
+// NewFromEnv creates a new Google provider from the GOOGLE_CLIENT_ID,
+// GOOGLE_CLIENT_SECRET and GOOGLE_CALLBACK_URL environment variables, which
+// are required, and the optional GOOGLE_SCOPES (separated by commas or spaces).
+// Each variable name is preceded by prefix, e.g. with a prefix of "WORKSPACE_"
+// the client id is read from WORKSPACE_GOOGLE_CLIENT_ID. This allows configuring
+// more than one Google provider; give each of them a distinct name with SetName.
+func NewFromEnv(prefix string) (*Provider, error) {
+	var missing []string
+	lookup := func(name string, required bool) string {
+		value := os.Getenv(prefix + name)
+		if value == "" && required {
+			missing = append(missing, prefix+name)
+		}
+		return value
+	}
+
+	clientKey := lookup("GOOGLE_CLIENT_ID", true)
+	secret := lookup("GOOGLE_CLIENT_SECRET", true)
+	callbackURL := lookup("GOOGLE_CALLBACK_URL", true)
+	scopes := strings.FieldsFunc(lookup("GOOGLE_SCOPES", false), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("google: missing required environment variables: %s", strings.Join(missing, ", "))
+	}
+	return New(clientKey, secret, callbackURL, scopes...), nil
+}
+
 // Provider is the implementation of `goth.Provider` for accessing Google.
 type Provider struct {
 	ClientKey       string
 	Secret          string
-	CallbackURL     string
+	CallbackURL     string // prefer SetCallbackURL, which validates the URL
 	HTTPClient      *http.Client
 	config          *oauth2.Config
 	authCodeOptions []oauth2.AuthCodeOption
+	tokenOptions    []oauth2.AuthCodeOption
+	pkceMethod      string
 	providerName    string
+	capturedHeaders []string
+	sessionCipher   *sessionCipher
+	maxResponseSize int64
+	onTokenRefresh  func(*oauth2.Token)
+
+	requireVerifiedEmail bool
+	requiredFields       []string
+	strictDecoding       bool
+	allowedHostedDomains []string
+	hostedDomain         string
+	authOnly             bool
+	userCache            *userCache
+	requestHeaders       map[string]string
+	rawDataTransform     func(map[string]interface{}) map[string]interface{}
+	verifyNonce          bool
+	accessTokenInQuery   bool
+	requiredScopes       []string
+	onDeniedScopes       func(denied []string)
+	preferIDToken        bool
+	userAgent            string
+	adminInstalled       []string
+	maxAge               int
+	clockSkewLeeway      time.Duration
 }
 
 // Name is the name used to retrieve this provider later.
@@ -59,7 +150,92 @@ func (p *Provider) SetName(name string) {
 
 // Client returns an HTTP client to be used in all fetch operations.
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	if p.userAgent != "" {
+		return goth.WithUserAgent(goth.HTTPClientWithFallBack(p.HTTPClient), p.userAgent)
+	}
+	return goth.UserAgentClient(p.HTTPClient)
+}
+
+// SetUserAgent sets the User-Agent header sent with every request to Google,
+// overriding the one set with goth.SetUserAgent.
+func (p *Provider) SetUserAgent(ua string) {
+	p.userAgent = ua
+}
+
+// SetCallbackURL changes the URL Google redirects to after authentication,
+// e.g. to pick the callback of the current environment at startup. Unlike
+// assigning CallbackURL, it also updates the redirect URL used by BeginAuth
+// and the token exchange. The URL must be absolute.
+func (p *Provider) SetCallbackURL(callbackURL string) error {
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return err
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return fmt.Errorf("google: callback URL %q is not absolute", callbackURL)
+	}
+	p.CallbackURL = callbackURL
+	p.config.RedirectURL = callbackURL
+	return nil
+}
+
+// AuthEndpoint returns the URL users are sent to by BeginAuth.
+func (p *Provider) AuthEndpoint() string {
+	return p.config.Endpoint.AuthURL
+}
+
+// SetEndpoint replaces the auth and token URLs used by the provider, which
+// default to Endpoint, e.g. to run against a mock identity provider in tests.
+func (p *Provider) SetEndpoint(endpoint oauth2.Endpoint) {
+	p.config.Endpoint = endpoint
+}
+
+// ConfigureOAuth2 calls fn with the oauth2.Config used by the provider, for
+// the tweaks no setter covers. It must be called while setting the provider
+// up, before serving requests, as the config is read without locking.
+// The redirect URL is taken from CallbackURL on each request, so changing
+// RedirectURL has no effect; use SetCallbackURL instead.
+func (p *Provider) ConfigureOAuth2(fn func(*oauth2.Config)) {
+	fn(p.config)
+}
+
+// SetTokenEndpointAuthMethod sets how the client credentials are sent to the
+// token endpoint on exchange and refresh, see goth.TokenEndpointAuthStyle.
+// Google accepts both; they are sent in the request body by default.
+func (p *Provider) SetTokenEndpointAuthMethod(method string) error {
+	style, err := goth.TokenEndpointAuthStyle(method)
+	if err != nil {
+		return err
+	}
+	p.config.Endpoint.AuthStyle = style
+	return nil
+}
+
+// TokenEndpoint returns the URL of the token exchange and refresh requests.
+func (p *Provider) TokenEndpoint() string {
+	return p.config.Endpoint.TokenURL
+}
+
+// UserInfoEndpoint returns the URL FetchUser gets the user from.
+func (p *Provider) UserInfoEndpoint() string {
+	return endpointProfile
+}
+
+// GSIButtonConfig returns the settings a front end needs to render the Google
+// Identity Services button or One Tap prompt: "client_id", "login_uri" and
+// "scope", plus "hd" when sign in is restricted to a single hosted domain.
+// They are all public; the client secret is never included, so the map can
+// be served as is to the browser.
+func (p *Provider) GSIButtonConfig() map[string]string {
+	config := map[string]string{
+		"client_id": p.ClientKey,
+		"login_uri": p.CallbackURL,
+		"scope":     strings.Join(p.config.Scopes, " "),
+	}
+	if len(p.allowedHostedDomains) == 1 {
+		config["hd"] = p.allowedHostedDomains[0]
+	}
+	return config
 }
 
 // Debug is a no-op for the google package.
@@ -67,13 +243,224 @@ func (p *Provider) Debug(debug bool) {}
 
 // BeginAuth asks Google for an authentication endpoint.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	url := p.config.AuthCodeURL(state, p.authCodeOptions...)
-	session := &Session{
-		AuthURL: url,
+	return p.beginAuth(state, p.authCodeOptions), nil
+}
+
+// BeginAuthContext implements goth.ContextBeginner. Beginning the
+// authentication process with Google never blocks, so ctx is ignored.
+func (p *Provider) BeginAuthContext(ctx context.Context, state string) (goth.Session, error) {
+	return p.BeginAuth(state)
+}
+
+// ExchangeCode exchanges an authorization code obtained outside of BeginAuth,
+// such as the server auth code a mobile app sends to its backend, and returns
+// an authorized session ready for FetchUser. Pass oauth2.VerifierOption when
+// the code was requested with PKCE. The provider's HTTPClient is used unless
+// ctx already carries an oauth2.HTTPClient.
+func (p *Provider) ExchangeCode(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (goth.Session, error) {
+	if _, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); !ok {
+		ctx = goth.ContextWithClient(ctx, p.Client())
+	}
+	sess := &Session{cipher: p.sessionCipher}
+	if _, err := sess.exchange(ctx, p, code, opts...); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// BeginAuthOptions holds per-request overrides of the provider's settings,
+// see BeginAuthWith. Empty fields leave the provider's setting in place.
+type BeginAuthOptions struct {
+	// AccessType is "offline" to get a refresh token, or "online".
+	AccessType string
+	Prompt     string
+	LoginHint  string
+}
+
+// BeginAuthWith works like BeginAuth, but applies opts to this request only.
+// Use it to, for example, only ask for offline access on logins that actually
+// need a refresh token, while the same provider serves all other logins.
+func (p *Provider) BeginAuthWith(state string, opts BeginAuthOptions) (goth.Session, error) {
+	// copy so the shared options are never appended to
+	authCodeOptions := make([]oauth2.AuthCodeOption, len(p.authCodeOptions), len(p.authCodeOptions)+3)
+	copy(authCodeOptions, p.authCodeOptions)
+
+	if opts.AccessType != "" {
+		authCodeOptions = append(authCodeOptions, oauth2.SetAuthURLParam("access_type", opts.AccessType))
+	}
+	if opts.Prompt != "" {
+		authCodeOptions = append(authCodeOptions, oauth2.SetAuthURLParam("prompt", opts.Prompt))
+	}
+	if opts.LoginHint != "" {
+		authCodeOptions = append(authCodeOptions, oauth2.SetAuthURLParam("login_hint", opts.LoginHint))
+	}
+	return p.beginAuth(state, authCodeOptions), nil
+}
+
+// BeginAuthWithPrompt works like BeginAuth, but sends the given prompt,
+// e.g. "select_account", for this request only. It implements
+// gothic.PromptProvider.
+func (p *Provider) BeginAuthWithPrompt(state, prompt string) (goth.Session, error) {
+	return p.BeginAuthWith(state, BeginAuthOptions{Prompt: prompt})
+}
+
+// MissingScopes returns the scopes requested by the provider that are not
+// among the granted ones, e.g. Session.GrantedScopes. With granular consent
+// users pick the scopes they grant on the consent screen, so a successful
+// login does not mean that every scope was granted. Ask for the missing ones
+// with BeginIncrementalAuth when a feature needs them.
+func (p *Provider) MissingScopes(granted []string) []string {
+	return missingScopes(p.config.Scopes, granted)
+}
+
+// BeginIncrementalAuth works like BeginAuth, but only asks for the given
+// scopes, typically the result of MissingScopes, along with
+// include_granted_scopes so that the resulting token also covers the scopes
+// granted earlier. See
+// https://developers.google.com/identity/protocols/oauth2/web-server#incrementalAuth
+func (p *Provider) BeginIncrementalAuth(state string, scopes []string) (goth.Session, error) {
+	if len(scopes) == 0 {
+		return nil, errors.New("google: no scopes to ask for")
+	}
+	opts := make([]oauth2.AuthCodeOption, len(p.authCodeOptions), len(p.authCodeOptions)+2)
+	copy(opts, p.authCodeOptions)
+	opts = append(opts,
+		oauth2.SetAuthURLParam("scope", strings.Join(scopes, " ")),
+		oauth2.SetAuthURLParam("include_granted_scopes", "true"),
+	)
+	return p.beginAuth(state, opts), nil
+}
+
+// BeginAuthWithGrantedScopes works like BeginAuth, but only asks Google to
+// show the consent screen (prompt=consent) when the provider requests scopes
+// that are not among the ones the user has already granted. Otherwise the
+// prompt is left to the provider's defaults, which lets returning users sign
+// in without seeing the consent screen again. Consent is never forced for
+// apps installed by an administrator, see SetAdminInstalledDomains.
+func (p *Provider) BeginAuthWithGrantedScopes(state string, granted []string) (goth.Session, error) {
+	if p.NeedsConsent(granted) && len(p.adminInstalled) == 0 {
+		return p.BeginAuthWith(state, BeginAuthOptions{Prompt: "consent"})
+	}
+	return p.BeginAuth(state)
+}
+
+func (p *Provider) beginAuth(state string, opts []oauth2.AuthCodeOption) *Session {
+	// full slice expression so that opts is never appended to in place
+	opts = append(opts[:len(opts):len(opts)], p.redirectOptions()...)
+	sess := &Session{cipher: p.sessionCipher}
+	if p.verifyNonce {
+		sess.Nonce = goth.NewNonce()
+		opts = append(opts, oauth2.SetAuthURLParam("nonce", sess.Nonce))
+	}
+	if p.hostedDomain != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("hd", p.hostedDomain))
+	}
+	if p.maxAge > 0 {
+		opts = append(opts, oauth2.SetAuthURLParam("max_age", strconv.Itoa(p.maxAge)))
+	}
+	switch p.pkceMethod {
+	case PKCEMethodS256:
+		sess.CodeVerifier = oauth2.GenerateVerifier()
+		opts = append(opts, oauth2.S256ChallengeOption(sess.CodeVerifier))
+	case PKCEMethodPlain:
+		sess.CodeVerifier = oauth2.GenerateVerifier()
+		opts = append(opts,
+			oauth2.SetAuthURLParam("code_challenge", sess.CodeVerifier),
+			oauth2.SetAuthURLParam("code_challenge_method", PKCEMethodPlain),
+		)
+	}
+	sess.AuthURL = p.config.AuthCodeURL(state, opts...)
+	return sess
+}
+
+// redirectOptions sends CallbackURL as the redirect_uri, so that assigning the
+// field after New is not ignored. No option is returned when it is empty.
+func (p *Provider) redirectOptions() []oauth2.AuthCodeOption {
+	if p.CallbackURL == "" {
+		return nil
+	}
+	return []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("redirect_uri", p.CallbackURL)}
+}
+
+// NeedsConsent reports whether any of the scopes requested by the provider
+// is missing from the granted ones. The "email" and "profile" shorthands are
+// considered equal to their full https://www.googleapis.com/auth/userinfo.*
+// forms, as Google reports granted scopes with the full URL.
+func (p *Provider) NeedsConsent(granted []string) bool {
+	return len(missingScopes(p.config.Scopes, granted)) > 0
+}
+
+// missingScopes returns the scopes of wanted that are not in granted.
+func missingScopes(wanted, granted []string) []string {
+	have := make(map[string]bool, len(granted))
+	for _, scope := range granted {
+		have[normalizeScope(scope)] = true
+	}
+	var missing []string
+	for _, scope := range wanted {
+		if !have[normalizeScope(scope)] {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+// checkScopes reports the requested scopes the user did not grant to the
+// handler set with SetDeniedScopesHandler, and fails when one of the scopes
+// given to SetRequiredScopes is missing. Sessions that do not know their
+// granted scopes are not checked.
+func (p *Provider) checkScopes(sess *Session) error {
+	if len(sess.GrantedScopes) == 0 {
+		return nil
+	}
+	if p.onDeniedScopes != nil {
+		if denied := missingScopes(p.config.Scopes, sess.GrantedScopes); len(denied) > 0 {
+			p.onDeniedScopes(denied)
+		}
+	}
+	if missing := missingScopes(p.requiredScopes, sess.GrantedScopes); len(missing) > 0 {
+		return &ScopesNotGrantedError{Missing: missing}
+	}
+	return nil
+}
+
+func normalizeScope(scope string) string {
+	switch scope {
+	case "email":
+		return ScopeUserEmail
+	case "profile":
+		return ScopeUserProfile
+	}
+	return scope
+}
+
+// scopeFields lists the goth.User fields populated thanks to each scope.
+// Add an entry here when FetchUser starts reading data from a new scope.
+var scopeFields = map[string][]string{
+	"openid":  {"UserID", "IDToken"},
+	"email":   {"Email"},
+	"profile": {"Name", "FirstName", "LastName", "NickName", "AvatarURL"},
+}
+
+// ScopeFieldMap returns, for each scope known to the provider, the names of
+// the goth.User fields it populates, e.g. to tell users in a consent screen
+// what an application will see. Scopes are listed both in their shorthand and
+// full URL forms. The returned map is a copy and may be modified freely.
+func (p *Provider) ScopeFieldMap() map[string][]string {
+	m := make(map[string][]string, 2*len(scopeFields))
+	for scope, fields := range scopeFields {
+		m[scope] = append([]string(nil), fields...)
+		if full := normalizeScope(scope); full != scope {
+			m[full] = append([]string(nil), fields...)
+		}
 	}
-	return session, nil
+	return m
 }
 
+// ErrHostedDomainNotAllowed is returned by FetchUser when the user's hosted
+// domain is not one of those given to SetAllowedHostedDomains.
+var ErrHostedDomainNotAllowed = errors.New("google: hosted domain is not allowed")
+
 type googleUser struct {
 	ID        string `json:"id"`
 	Email     string `json:"email"`
@@ -82,10 +469,36 @@ type googleUser struct {
 	LastName  string `json:"family_name"`
 	Link      string `json:"link"`
 	Picture   string `json:"picture"`
+	HD        string `json:"hd"`
+	// the ID token identifies the user with sub rather than id
+	Sub string `json:"sub"`
+	// v2 of the userinfo endpoint uses verified_email, OpenID Connect email_verified
+	VerifiedEmail bool `json:"verified_email"`
+	EmailVerified bool `json:"email_verified"`
 }
 
 // FetchUser will go to Google and access basic information about the user.
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	user, _, err := p.FetchUserWithResponse(session)
+	return user, err
+}
+
+// FetchUserContext works like FetchUser, but gives up on the requests to
+// Google once ctx is done. It implements goth.ContextFetcher.
+func (p *Provider) FetchUserContext(ctx context.Context, session goth.Session) (goth.User, error) {
+	user, _, err := p.fetchUser(ctx, session)
+	return user, err
+}
+
+// FetchUserWithResponse works like FetchUser, but also returns the HTTP
+// status code of the userinfo request, whether it succeeded or not. The code
+// is 0 when no response was received, including when the user came from the
+// cache or, for providers created with NewAuthOnly, from the ID token.
+func (p *Provider) FetchUserWithResponse(session goth.Session) (goth.User, int, error) {
+	return p.fetchUser(context.Background(), session)
+}
+
+func (p *Provider) fetchUser(ctx context.Context, session goth.Session) (goth.User, int, error) {
 	sess := session.(*Session)
 	user := goth.User{
 		AccessToken:  sess.AccessToken,
@@ -95,29 +508,81 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		IDToken:      sess.IDToken,
 	}
 
+	if err := p.checkNonce(sess); err != nil {
+		return user, 0, err
+	}
+	if err := p.checkScopes(sess); err != nil {
+		return user, 0, err
+	}
+
+	if p.authOnly {
+		if user.IDToken == "" {
+			return user, 0, fmt.Errorf("%s cannot get user information without an ID token", p.providerName)
+		}
+		if err := p.verifyIDToken(user.IDToken); err != nil {
+			return user, 0, err
+		}
+		user, err := p.userFromIDToken(user, sess)
+		return user, 0, err
+	}
+
+	if p.preferIDToken && sess.IDToken != "" {
+		if u, err := p.userFromVerifiedIDToken(user, sess); err == nil {
+			return u, 0, nil
+		}
+	}
+
 	if user.AccessToken == "" {
 		// Data is not yet retrieved, since accessToken is still empty.
-		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+		return user, 0, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	if p.userCache != nil {
+		if cached, ok := p.userCache.get(user.AccessToken); ok {
+			return cached, 0, nil
+		}
 	}
 
-	response, err := p.Client().Get(endpointProfile + "?access_token=" + url.QueryEscape(sess.AccessToken))
+	endpoint := endpointProfile
+	if p.accessTokenInQuery {
+		endpoint += "?access_token=" + url.QueryEscape(sess.AccessToken)
+	}
+	req, err := http.NewRequest("GET", endpoint, nil)
 	if err != nil {
-		return user, err
+		return user, 0, err
+	}
+	req = req.WithContext(ctx)
+	for name, value := range p.requestHeaders {
+		req.Header.Set(name, value)
+	}
+	if !p.accessTokenInQuery {
+		req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+	}
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return user, 0, err
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusOK {
-		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, response.StatusCode)
+		err := fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, response.StatusCode)
+		if response.StatusCode == http.StatusTooManyRequests {
+			err = &QuotaError{RetryAfter: parseRetryAfter(response.Header.Get("Retry-After"), time.Now()), Err: err}
+		}
+		return user, response.StatusCode, err
 	}
 
-	responseBytes, err := ioutil.ReadAll(response.Body)
+	responseBytes, err := goth.ReadAllLimited(response.Body, p.maxResponseSize)
 	if err != nil {
-		return user, err
+		return user, response.StatusCode, fmt.Errorf("%s failed to read user information: %w", p.providerName, err)
 	}
 
 	var u googleUser
 	if err := json.Unmarshal(responseBytes, &u); err != nil {
-		return user, err
+		if p.strictDecoding {
+			return user, response.StatusCode, decodeError(p.providerName, responseBytes, err)
+		}
+		return user, response.StatusCode, err
 	}
 
 	// Extract the user data we got from Google into our goth.User.
@@ -127,13 +592,241 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	user.NickName = u.Name
 	user.Email = u.Email
 	user.AvatarURL = u.Picture
+	user.Avatars = avatars(u.Picture)
 	user.UserID = u.ID
 	// Google provides other useful fields such as 'hd'; get them from RawData
 	if err := json.Unmarshal(responseBytes, &user.RawData); err != nil {
+		return user, response.StatusCode, err
+	}
+	if user.RawData == nil {
+		user.RawData = map[string]interface{}{}
+	}
+	if p.hasScope(ScopeEmailsRead) {
+		// in Workspace, the canonical address may be an alias of the userinfo email
+		user.RawData["emails"] = p.emails(ctx, user.AccessToken, u)
+	}
+	p.setAdminInstalled(&user, sess)
+	if p.rawDataTransform != nil {
+		user.RawData = p.rawDataTransform(user.RawData)
+	}
+
+	if err := p.checkUser(u); err != nil {
+		return user, response.StatusCode, err
+	}
+	if err := user.Require(p.requiredFields...); err != nil {
+		return user, response.StatusCode, err
+	}
+
+	if len(p.capturedHeaders) > 0 {
+		headers := map[string]interface{}{}
+		for _, name := range p.capturedHeaders {
+			if value := response.Header.Get(name); value != "" {
+				headers[http.CanonicalHeaderKey(name)] = value
+			}
+		}
+		if user.RawData == nil {
+			user.RawData = map[string]interface{}{}
+		}
+		user.RawData["_headers"] = headers
+	}
+
+	if p.userCache != nil {
+		p.userCache.set(user.AccessToken, user)
+	}
+	return user, response.StatusCode, nil
+}
+
+// FetchUserAndToken behaves like FetchUser but also returns the token held by
+// the session, for callers that persist the token separately from the user
+// profile. The ID token is available with Token.Extra("id_token").
+func (p *Provider) FetchUserAndToken(session goth.Session) (goth.User, *oauth2.Token, error) {
+	sess := session.(*Session)
+	user, err := p.FetchUser(sess)
+	if err != nil {
+		return user, nil, err
+	}
+	token := &oauth2.Token{
+		AccessToken:  sess.AccessToken,
+		TokenType:    "Bearer",
+		RefreshToken: sess.RefreshToken,
+		Expiry:       sess.ExpiresAt,
+	}
+	return user, token.WithExtra(map[string]interface{}{"id_token": sess.IDToken}), nil
+}
+
+// userFromIDToken fills user from the claims of its ID token, which must have
+// been verified with verifyIDToken.
+func (p *Provider) userFromIDToken(user goth.User, sess *Session) (goth.User, error) {
+	claims, raw, err := p.idTokenClaims(user.IDToken)
+	if err != nil {
 		return user, err
 	}
 
-	return user, nil
+	u := googleUser{
+		Email:         claims.Email,
+		Name:          claims.Name,
+		FirstName:     claims.GivenName,
+		LastName:      claims.FamilyName,
+		Picture:       claims.Picture,
+		HD:            claims.HostedDomain,
+		Sub:           claims.Subject,
+		EmailVerified: bool(claims.EmailVerified),
+	}
+	user.RawData = raw
+	p.setAdminInstalled(&user, sess)
+	if p.rawDataTransform != nil {
+		user.RawData = p.rawDataTransform(user.RawData)
+	}
+
+	user.UserID = u.Sub
+	user.Name = u.Name
+	user.FirstName = u.FirstName
+	user.LastName = u.LastName
+	user.NickName = u.Name
+	user.Email = u.Email
+	user.AvatarURL = u.Picture
+	user.Avatars = avatars(u.Picture)
+
+	if err := p.checkUser(u); err != nil {
+		return user, err
+	}
+	return user, user.Require(p.requiredFields...)
+}
+
+// avatarSizes are the sizes listed in User.Avatars.
+var avatarSizes = []int{48, 96, 200, 400}
+
+// avatarSizeSuffix matches the size option ending Google profile picture URLs,
+// e.g. "=s96-c".
+var avatarSizeSuffix = regexp.MustCompile(`=s\d+(-c)?$`)
+
+// avatars derives pictures of common sizes from the picture URL returned by
+// Google, which serves any size by changing the "=s<size>-c" suffix of the
+// URL. Other URLs are returned as is, with an unknown size.
+func avatars(picture string) []goth.Avatar {
+	if picture == "" {
+		return nil
+	}
+	u, err := url.Parse(picture)
+	if err != nil || !strings.HasSuffix(u.Host, ".googleusercontent.com") {
+		return []goth.Avatar{{URL: picture}}
+	}
+	base := avatarSizeSuffix.ReplaceAllString(picture, "")
+	list := make([]goth.Avatar, 0, len(avatarSizes))
+	for _, size := range avatarSizes {
+		list = append(list, goth.Avatar{URL: fmt.Sprintf("%s=s%d-c", base, size), Size: size})
+	}
+	return list
+}
+
+// verifyIDToken checks the signature, issuer, expiry and audience of idToken.
+func (p *Provider) verifyIDToken(idToken string) error {
+	claims := &IDTokenClaims{}
+	if err := parseIDToken(p.Client(), idToken, claims, p.clockSkewLeeway); err != nil {
+		return err
+	}
+	if !claims.VerifyAudience(p.ClientKey, true) {
+		return errors.New("google: ID token was not issued to this client")
+	}
+	return nil
+}
+
+// userFromVerifiedIDToken fills user from its ID token once its signature,
+// issuer, audience and expiry have been verified. It fails when the token is
+// not valid or lacks the email or a required field, in which case FetchUser
+// asks the userinfo endpoint instead.
+func (p *Provider) userFromVerifiedIDToken(user goth.User, sess *Session) (goth.User, error) {
+	if err := p.verifyIDToken(user.IDToken); err != nil {
+		return user, err
+	}
+	user, err := p.userFromIDToken(user, sess)
+	if err != nil {
+		return user, err
+	}
+	return user, user.Require("UserID", "Email")
+}
+
+// idTokenClaims returns the claims of an ID token. The signature is not
+// verified: the token comes straight from Google's token endpoint over TLS.
+func (p *Provider) idTokenClaims(idToken string) (goth.StandardClaims, map[string]interface{}, error) {
+	claims, raw, err := goth.ParseUnverifiedClaims(idToken)
+	if err != nil {
+		return claims, nil, fmt.Errorf("%s returned a malformed ID token: %w", p.providerName, err)
+	}
+	return claims, raw, nil
+}
+
+// checkNonce returns goth.ErrNonceMismatch when the session expects a nonce
+// that its ID token does not carry. Sessions started without a nonce, or
+// holding no ID token, are not checked.
+func (p *Provider) checkNonce(sess *Session) error {
+	if sess.Nonce == "" || sess.IDToken == "" {
+		return nil
+	}
+	claims, _, err := p.idTokenClaims(sess.IDToken)
+	if err != nil {
+		return err
+	}
+	if claims.Nonce != sess.Nonce {
+		return goth.ErrNonceMismatch
+	}
+	return nil
+}
+
+// checkAuthAge returns goth.ErrReauthRequired when the ID token shows that
+// the user authenticated longer ago than allowed by SetMaxAge. It runs on the
+// code exchange only, as a stored session naturally ages past max_age.
+// Sessions holding no ID token are not checked.
+func (p *Provider) checkAuthAge(sess *Session) error {
+	if p.maxAge <= 0 || sess.IDToken == "" {
+		return nil
+	}
+	claims, _, err := p.idTokenClaims(sess.IDToken)
+	if err != nil {
+		return err
+	}
+	return claims.CheckAuthAge(time.Duration(p.maxAge)*time.Second, p.clockSkewLeeway)
+}
+
+// checkUser enforces the restrictions set with SetRequireVerifiedEmail and
+// SetAllowedHostedDomains.
+func (p *Provider) checkUser(u googleUser) error {
+	if p.requireVerifiedEmail && !(u.VerifiedEmail || u.EmailVerified) {
+		return goth.ErrEmailNotVerified
+	}
+
+	if len(p.allowedHostedDomains) > 0 && !p.hostedDomainAllowed(u.HD) {
+		return ErrHostedDomainNotAllowed
+	}
+	return nil
+}
+
+// decodeError describes where the user information returned by Google did not
+// match the expected shape. The original error is wrapped.
+func decodeError(providerName string, data []byte, err error) error {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		return fmt.Errorf("%s returned unexpected user information: field %q is a JSON %s, expected %s (offset %d): %w",
+			providerName, typeErr.Field, typeErr.Value, typeErr.Type, typeErr.Offset, err)
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("%s returned malformed user information at offset %d near %q: %w",
+			providerName, syntaxErr.Offset, excerpt(data, syntaxErr.Offset), err)
+	}
+	return fmt.Errorf("%s returned unexpected user information: %w", providerName, err)
+}
+
+// excerpt returns up to 20 bytes of data on each side of offset.
+func excerpt(data []byte, offset int64) string {
+	start, end := offset-20, offset+20
+	if start < 0 {
+		start = 0
+	}
+	if end > int64(len(data)) {
+		end = int64(len(data))
+	}
+	return string(data[start:end])
 }
 
 func newConfig(provider *Provider, scopes []string) *oauth2.Config {
@@ -155,16 +848,26 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 
 // RefreshTokenAvailable refresh token is provided by auth provider or not
 func (p *Provider) RefreshTokenAvailable() bool {
-	return true
+	return !p.authOnly
 }
 
-// RefreshToken get new access token based on the refresh token
+// RefreshToken get new access token based on the refresh token.
+// When the refresh token has been revoked or has expired the error matches
+// ErrRefreshTokenInvalid and the user has to sign in again. When Google
+// refuses the refresh because a quota was exceeded the error matches
+// ErrQuotaExceeded and is a *QuotaError carrying the retry delay.
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return p.RefreshTokenContext(context.Background(), refreshToken)
+}
+
+// RefreshTokenContext works like RefreshToken, but gives up once ctx is done.
+// It implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenContext(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
 	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
+	ts := p.config.TokenSource(goth.ContextWithClient(ctx, p.Client()), token)
 	newToken, err := ts.Token()
 	if err != nil {
-		return nil, err
+		return nil, refreshError(err)
 	}
 	return newToken, err
 }
@@ -180,14 +883,162 @@ func (p *Provider) SetPrompt(prompt ...string) {
 	p.authCodeOptions = append(p.authCodeOptions, oauth2.SetAuthURLParam("prompt", strings.Join(prompt, " ")))
 }
 
+// LogoutURL always returns goth.ErrLogoutNotSupported: Google has no end
+// session endpoint and users cannot be logged out of their Google account by
+// the application. Use Revoke to invalidate the tokens issued to the
+// application instead.
+func (p *Provider) LogoutURL(idToken, postLogoutRedirect string) (string, error) {
+	return "", goth.ErrLogoutNotSupported
+}
+
+// Revoke invalidates the given access or refresh token. Revoking a refresh
+// token also revokes the access tokens issued with it, and the user will have
+// to grant access again on their next sign in.
+func (p *Provider) Revoke(token string) error {
+	form := url.Values{"token": {token}}
+	response, err := p.Client().PostForm(endpointRevoke, form)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with a %d trying to revoke the token", p.providerName, response.StatusCode)
+	}
+	return nil
+}
+
+// SetUserCache makes FetchUser reuse the user fetched for an access token for
+// ttl, instead of calling the userinfo endpoint again, e.g. when both a
+// middleware and a handler fetch the user during the same request. At most
+// size users are kept. Keep ttl short: changes to the user's profile are not
+// seen until the entry expires. A ttl or size of zero disables the cache,
+// which is the default.
+func (p *Provider) SetUserCache(ttl time.Duration, size int) {
+	if ttl <= 0 || size <= 0 {
+		p.userCache = nil
+		return
+	}
+	p.userCache = newUserCache(ttl, size)
+}
+
+// SetRequestHeaders sets headers sent with the userinfo request of FetchUser,
+// e.g. an API key required by a proxy in front of Google's API. The values
+// may be secrets and must never be logged.
+func (p *Provider) SetRequestHeaders(headers map[string]string) {
+	p.requestHeaders = make(map[string]string, len(headers))
+	for name, value := range headers {
+		p.requestHeaders[name] = value
+	}
+}
+
+// SetTokenParams adds parameters to the token exchange request sent by
+// Session.Authorize, unlike SetPrompt and the other setters which only change
+// the auth URL. Build them with oauth2.SetAuthURLParam, e.g.
+//
+//	p.SetTokenParams(oauth2.SetAuthURLParam("code_verifier", verifier))
+func (p *Provider) SetTokenParams(opts ...oauth2.AuthCodeOption) {
+	p.tokenOptions = append(p.tokenOptions, opts...)
+}
+
+// SetPKCEMethod enables PKCE with the given code challenge method, either
+// PKCEMethodS256 or PKCEMethodPlain; an empty method means PKCEMethodS256.
+// Only use PKCEMethodPlain for clients that cannot compute SHA-256, as the
+// challenge is then the verifier itself. The verifier is kept in the session
+// until the token exchange, consider SetSessionEncryptionKeys when sessions
+// are stored client side.
+func (p *Provider) SetPKCEMethod(method string) error {
+	switch method {
+	case "":
+		method = PKCEMethodS256
+	case PKCEMethodS256, PKCEMethodPlain:
+	default:
+		return fmt.Errorf("google: unknown PKCE method %q, expected %q or %q", method, PKCEMethodS256, PKCEMethodPlain)
+	}
+	p.pkceMethod = method
+	return nil
+}
+
 // SetHostedDomain sets the hd parameter for google OAuth call.
 // Use this to force user to pick user from specific hosted domain.
+// Calling it again replaces the domain, and an empty hd stops sending it.
 // See https://developers.google.com/identity/protocols/oauth2/openid-connect#hd-param
 func (p *Provider) SetHostedDomain(hd string) {
+	p.hostedDomain = hd
+}
+
+// SetAllowedHostedDomains restricts sign in to users of the given Google
+// Workspace domains. FetchUser returns ErrHostedDomainNotAllowed for any other
+// user, including consumer accounts which have no hosted domain.
+// With a single domain the hd parameter is also sent so that Google only
+// offers accounts of that domain; hd takes one value only, so it is omitted
+// when several domains are allowed.
+func (p *Provider) SetAllowedHostedDomains(domains ...string) {
+	p.allowedHostedDomains = nil
+	for _, d := range domains {
+		if d != "" {
+			p.allowedHostedDomains = append(p.allowedHostedDomains, strings.ToLower(d))
+		}
+	}
+	p.hostedDomain = ""
+	if len(p.allowedHostedDomains) == 1 {
+		p.hostedDomain = p.allowedHostedDomains[0]
+	}
+}
+
+func (p *Provider) hostedDomainAllowed(hd string) bool {
 	if hd == "" {
+		return false
+	}
+	hd = strings.ToLower(hd)
+	for _, d := range p.allowedHostedDomains {
+		if d == hd {
+			return true
+		}
+	}
+	return false
+}
+
+// SetAdminInstalledDomains declares the Google Workspace domains whose
+// administrator installed the application domain-wide, e.g. from the
+// Workspace Marketplace, granting its scopes on behalf of all their users.
+// Such users should not be shown a consent screen, so BeginAuthWithGrantedScopes
+// no longer forces one with prompt=consent; avoid SetPrompt("consent") too.
+// Google still shows the consent screen to other users when needed.
+//
+// Google does not report whether consent was granted by an administrator.
+// FetchUser sets RawData["admin_installed_domain"] to true when the user
+// belongs to one of these domains and, when Google reported the granted
+// scopes, all the requested scopes were granted. It is set before the
+// function given to SetRawDataTransform runs, whichever way the user was
+// fetched.
+func (p *Provider) SetAdminInstalledDomains(domains ...string) {
+	p.adminInstalled = nil
+	for _, d := range domains {
+		if d != "" {
+			p.adminInstalled = append(p.adminInstalled, strings.ToLower(d))
+		}
+	}
+}
+
+// setAdminInstalled sets RawData["admin_installed_domain"], see
+// SetAdminInstalledDomains.
+func (p *Provider) setAdminInstalled(user *goth.User, sess *Session) {
+	if len(p.adminInstalled) == 0 || user.RawData == nil {
 		return
 	}
-	p.authCodeOptions = append(p.authCodeOptions, oauth2.SetAuthURLParam("hd", hd))
+	hd, _ := user.RawData["hd"].(string)
+	installed := false
+	for _, d := range p.adminInstalled {
+		if hd != "" && strings.EqualFold(d, hd) {
+			installed = true
+			break
+		}
+	}
+	if installed && sess.GrantedScopes != nil {
+		installed = len(missingScopes(p.config.Scopes, sess.GrantedScopes)) == 0
+	}
+	user.RawData["admin_installed_domain"] = installed
 }
 
 // SetLoginHint sets the login_hint parameter for the Google OAuth call.
@@ -209,3 +1060,188 @@ func (p *Provider) SetAccessType(at string) {
 	}
 	p.authCodeOptions = append(p.authCodeOptions, oauth2.SetAuthURLParam("access_type", at))
 }
+
+// SetCapturedHeaders sets the names of the userinfo response headers (such as
+// "X-RateLimit-Remaining" or "ETag") that FetchUser copies into
+// RawData["_headers"]. This is meant for diagnostics and is off by default;
+// call it with no arguments to turn it off again.
+func (p *Provider) SetCapturedHeaders(names ...string) {
+	p.capturedHeaders = names
+}
+
+// SetSessionEncryptionKeys turns on encryption of marshaled sessions. The
+// session carries the access and refresh tokens and is usually persisted in
+// a cookie by gothic, where it would otherwise only be encoded. Sessions are
+// sealed with AES-GCM using current, which must be 16, 24 or 32 bytes long.
+// Any old keys are only used to decrypt, which allows rotating the key
+// without invalidating sessions that are in flight.
+func (p *Provider) SetSessionEncryptionKeys(current []byte, old ...[]byte) error {
+	c, err := newSessionCipher(current, old...)
+	if err != nil {
+		return err
+	}
+	p.sessionCipher = c
+	return nil
+}
+
+// SetMaxResponseSize limits how many bytes FetchUser reads from the userinfo
+// endpoint before giving up with goth.ErrResponseTooLarge. It defaults to
+// goth.DefaultMaxResponseSize.
+func (p *Provider) SetMaxResponseSize(n int64) {
+	p.maxResponseSize = n
+}
+
+// SetTokenRefreshCallback registers a function that is called with the new
+// token whenever a client returned by AuthenticatedClient refreshes its
+// access token, so that it can be persisted.
+func (p *Provider) SetTokenRefreshCallback(fn func(*oauth2.Token)) {
+	p.onTokenRefresh = fn
+}
+
+// AuthenticatedClient returns an HTTP client for calling Google APIs on behalf
+// of the user of the given session. The client adds the access token to every
+// request and uses the refresh token to get a new one once it has expired.
+// Requests go through the provider's HTTPClient, if one is set.
+// Tokens are refreshed on demand while sending a request; no goroutine is left
+// running in the background, so the client needs no shutdown. Cancelling ctx
+// stops any further refresh.
+func (p *Provider) AuthenticatedClient(ctx context.Context, session goth.Session) *http.Client {
+	sess := session.(*Session)
+	token := &oauth2.Token{
+		AccessToken:  sess.AccessToken,
+		RefreshToken: sess.RefreshToken,
+		Expiry:       sess.ExpiresAt,
+		TokenType:    "Bearer",
+	}
+	ctx = goth.ContextWithClient(ctx, p.Client())
+	ts := &notifyingTokenSource{
+		src:    p.config.TokenSource(ctx, token),
+		last:   token.AccessToken,
+		notify: p.onTokenRefresh,
+	}
+	return oauth2.NewClient(ctx, ts)
+}
+
+// notifyingTokenSource calls notify every time src hands out a token that
+// differs from the previous one, i.e. after a refresh.
+type notifyingTokenSource struct {
+	src    oauth2.TokenSource
+	notify func(*oauth2.Token)
+
+	mu   sync.Mutex
+	last string
+}
+
+func (s *notifyingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	refreshed := token.AccessToken != s.last
+	s.last = token.AccessToken
+	s.mu.Unlock()
+
+	if refreshed && s.notify != nil {
+		s.notify(token)
+	}
+	return token, nil
+}
+
+// SetRawDataTransform sets a function applied by FetchUser to the profile
+// data returned by Google before it is stored in User.RawData, e.g. to drop
+// the fields an application has no use for. The typed User fields are not
+// affected. A nil function, the default, keeps the data as is.
+func (p *Provider) SetRawDataTransform(transform func(map[string]interface{}) map[string]interface{}) {
+	p.rawDataTransform = transform
+}
+
+// SetRequiredScopes makes FetchUser fail with a *ScopesNotGrantedError when
+// the user did not grant all the given scopes on the consent screen, e.g.
+// when the application cannot work without Drive access. Scopes granted by
+// Google are known once Session.Authorize has run.
+func (p *Provider) SetRequiredScopes(scopes ...string) {
+	p.requiredScopes = scopes
+}
+
+// SetDeniedScopesHandler sets a function called by FetchUser with the
+// requested scopes the user did not grant, so that partial consent can be
+// logged or otherwise acted upon.
+func (p *Provider) SetDeniedScopesHandler(fn func(denied []string)) {
+	p.onDeniedScopes = fn
+}
+
+// SetAccessTokenInQuery makes FetchUser send the access token in the
+// access_token query parameter, as it used to, instead of the Authorization
+// header. Tokens in URLs tend to end up in proxy and access logs, so only
+// turn this on for setups that depend on it.
+func (p *Provider) SetAccessTokenInQuery(enabled bool) {
+	p.accessTokenInQuery = enabled
+}
+
+// SetPreferIDToken makes FetchUser build the user from the ID token, when
+// the session holds one, after verifying it with Google's signing keys. The
+// keys are cached, so the common case needs no call to Google at all, which
+// keeps logins working while the userinfo endpoint is unavailable. The
+// userinfo endpoint is still used when there is no ID token, when it is not
+// valid, or when it lacks the email or a field set with SetRequiredFields.
+func (p *Provider) SetPreferIDToken(prefer bool) {
+	p.preferIDToken = prefer
+}
+
+// SetClockSkewLeeway sets how far the exp, iat and nbf claims of ID tokens
+// may be off when FetchUser verifies them, see SetPreferIDToken, to allow for
+// clock skew between this server and Google. It also applies to the
+// auth_time check of SetMaxAge. It defaults to
+// DefaultClockSkewLeeway; a negative d means no leeway.
+func (p *Provider) SetClockSkewLeeway(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	p.clockSkewLeeway = d
+}
+
+// SetMaxAge sends the max_age parameter, asking Google to authenticate the
+// user again when they last did more than seconds ago, e.g. before a
+// sensitive action. The code exchange, Authorize or ExchangeCode, then fails
+// with goth.ErrReauthRequired unless the auth_time claim of the ID token is
+// within that window, give or take the clock skew leeway; sessions without an
+// ID token, when the openid scope is not requested, are not checked. Zero,
+// the default, turns this off.
+func (p *Provider) SetMaxAge(seconds int) {
+	p.maxAge = seconds
+}
+
+// SetNonceVerification turns the nonce check on or off; it is off by
+// default. When on, BeginAuth sends a random nonce that is kept in the
+// session, and FetchUser fails with goth.ErrNonceMismatch unless the ID token
+// carries the same nonce claim, which prevents ID tokens issued for another
+// login from being replayed.
+func (p *Provider) SetNonceVerification(enabled bool) {
+	p.verifyNonce = enabled
+}
+
+// SetRequiredFields makes FetchUser fail with a *goth.MissingFieldError when
+// one of the given fields is empty once the profile has been fetched, e.g.
+// "email" when the email scope was not granted. See goth.User.Require for the
+// accepted field names.
+func (p *Provider) SetRequiredFields(fields ...string) {
+	p.requiredFields = fields
+}
+
+// SetRequireVerifiedEmail makes FetchUser fail with goth.ErrEmailNotVerified
+// when Google does not report the user's email address as verified. Turn this
+// on when accounts are linked by email address. It is off by default.
+func (p *Provider) SetRequireVerifiedEmail(require bool) {
+	p.requireVerifiedEmail = require
+}
+
+// SetStrictDecoding makes FetchUser describe which field of the user
+// information returned by Google did not have the expected type, or where the
+// response stopped being valid JSON. Unknown fields are still accepted, so new
+// fields added by Google never cause an error. This is meant for debugging
+// integrations and is off by default.
+func (p *Provider) SetStrictDecoding(strict bool) {
+	p.strictDecoding = strict
+}
@@ -1,13 +1,34 @@
 package google_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jarcoal/httpmock"
+	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/google"
+	"github.com/markbates/goth/testsupport"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
 )
 
 func Test_New(t *testing.T) {
@@ -20,6 +41,28 @@ func Test_New(t *testing.T) {
 	a.Equal(provider.CallbackURL, "/foo")
 }
 
+func Test_NewFromEnv(t *testing.T) {
+	a := assert.New(t)
+
+	_, err := google.NewFromEnv("GOTH_TEST_")
+	a.EqualError(err, "google: missing required environment variables: GOTH_TEST_GOOGLE_CLIENT_ID, GOTH_TEST_GOOGLE_CLIENT_SECRET, GOTH_TEST_GOOGLE_CALLBACK_URL")
+
+	t.Setenv("GOTH_TEST_GOOGLE_CLIENT_ID", "client-id")
+	t.Setenv("GOTH_TEST_GOOGLE_CLIENT_SECRET", "client-secret")
+	t.Setenv("GOTH_TEST_GOOGLE_CALLBACK_URL", "http://localhost/callback")
+	t.Setenv("GOTH_TEST_GOOGLE_SCOPES", "email, profile")
+
+	provider, err := google.NewFromEnv("GOTH_TEST_")
+	a.NoError(err)
+	a.Equal("client-id", provider.ClientKey)
+	a.Equal("client-secret", provider.Secret)
+	a.Equal("http://localhost/callback", provider.CallbackURL)
+
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*google.Session).AuthURL, "scope=email+profile")
+}
+
 func Test_BeginAuth(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -95,6 +138,105 @@ func Test_BeginAuthWithLoginHint(t *testing.T) {
 	a.Contains(s.AuthURL, "login_hint=john%40example.com")
 }
 
+func Test_BeginAuthWith(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+
+	session, err := provider.BeginAuthWith("test_state", google.BeginAuthOptions{AccessType: "online", Prompt: "select_account"})
+	a.NoError(err)
+	s := session.(*google.Session)
+	a.Contains(s.AuthURL, "access_type=online")
+	a.NotContains(s.AuthURL, "access_type=offline")
+	a.Contains(s.AuthURL, "prompt=select_account")
+
+	// the per-request options must not leak into the provider
+	for i := 0; i < 2; i++ {
+		session, err = provider.BeginAuth("test_state")
+		a.NoError(err)
+		s = session.(*google.Session)
+		a.Contains(s.AuthURL, "access_type=offline")
+		a.NotContains(s.AuthURL, "prompt=")
+	}
+}
+
+func Test_BeginAuthWithGrantedScopes(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := google.New(os.Getenv("GOOGLE_KEY"), os.Getenv("GOOGLE_SECRET"), "/foo", "email", "https://www.googleapis.com/auth/drive.readonly")
+
+	a.True(provider.NeedsConsent(nil))
+	a.True(provider.NeedsConsent([]string{"https://www.googleapis.com/auth/userinfo.email"}))
+	a.False(provider.NeedsConsent([]string{"openid", "https://www.googleapis.com/auth/userinfo.email", "https://www.googleapis.com/auth/drive.readonly"}))
+
+	session, err := provider.BeginAuthWithGrantedScopes("test_state", []string{"email"})
+	a.NoError(err)
+	a.Contains(session.(*google.Session).AuthURL, "prompt=consent")
+
+	session, err = provider.BeginAuthWithGrantedScopes("test_state", []string{"email", "https://www.googleapis.com/auth/drive.readonly"})
+	a.NoError(err)
+	a.NotContains(session.(*google.Session).AuthURL, "prompt=")
+
+	// the provider's own options must not be changed
+	session, err = provider.BeginAuth("test_state")
+	a.NoError(err)
+	a.NotContains(session.(*google.Session).AuthURL, "prompt=")
+}
+
+func Test_SetAdminInstalledDomains(t *testing.T) {
+	// not parallel: the key set is cached process wide
+	a := assert.New(t)
+
+	provider := google.New(os.Getenv("GOOGLE_KEY"), os.Getenv("GOOGLE_SECRET"), "/foo", "email")
+	provider.SetAdminInstalledDomains("Acme.com")
+
+	session, err := provider.BeginAuthWithGrantedScopes("test_state", nil)
+	a.NoError(err)
+	a.NotContains(session.(*google.Session).AuthURL, "prompt=")
+
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"1234","email":"homer@%[1]s","hd":%[1]q}`, r.Header.Get("X-Domain"))
+	})
+	fetch := func(hd string, granted []string) interface{} {
+		provider.SetRequestHeaders(map[string]string{"X-Domain": hd})
+		user, err := provider.FetchUser(&google.Session{AccessToken: "access", GrantedScopes: granted})
+		a.NoError(err)
+		return user.RawData["admin_installed_domain"]
+	}
+	a.Equal(true, fetch("acme.com", nil))
+	a.Equal(true, fetch("acme.com", []string{"openid", "https://www.googleapis.com/auth/userinfo.email"}))
+	a.Equal(false, fetch("acme.com", []string{"openid"}))
+	a.Equal(false, fetch("other.com", nil))
+
+	// it is set before the transform, from the ID token too
+	sign, keys := testSigningKey(a)
+	provider = google.New("client", "secret", "/foo", "email")
+	provider.SetAdminInstalledDomains("acme.com")
+	provider.SetPreferIDToken(true)
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("/oauth2/v3/certs", r.URL.Path, "userinfo must not be called")
+		w.Write(keys)
+	})
+	var seen interface{}
+	provider.SetRawDataTransform(func(raw map[string]interface{}) map[string]interface{} {
+		seen = raw["admin_installed_domain"]
+		return raw
+	})
+	_, err = provider.FetchUser(&google.Session{AccessToken: "access", IDToken: sign(jwt.MapClaims{
+		"iss":   "https://accounts.google.com",
+		"aud":   "client",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"sub":   "1234",
+		"email": "homer@acme.com",
+		"hd":    "acme.com",
+	})})
+	a.NoError(err)
+	a.Equal(true, seen)
+}
+
 func Test_Implements_Provider(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -115,6 +257,1762 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(session.AccessToken, "1234567890")
 }
 
+func Test_FetchUserCapturedHeaders(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		w.Header().Set("ETag", "abc")
+		fmt.Fprint(w, `{"id":"1234","email":"homer@example.com","name":"Homer Simpson"}`)
+	})
+
+	session := &google.Session{AccessToken: "1234567890"}
+	user, err := provider.FetchUser(session)
+	a.NoError(err)
+	a.NotContains(user.RawData, "_headers")
+
+	provider.SetCapturedHeaders("x-ratelimit-remaining", "Missing")
+	user, err = provider.FetchUser(session)
+	a.NoError(err)
+	a.Equal("1234", user.UserID)
+	a.Equal(map[string]interface{}{"X-Ratelimit-Remaining": "42"}, user.RawData["_headers"])
+}
+
+func Test_SessionEncryption(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	oldKey := []byte("0123456789abcdef0123456789abcdef")
+	newKey := []byte("fedcba9876543210fedcba9876543210")
+
+	provider := googleProvider()
+	a.Error(provider.SetSessionEncryptionKeys([]byte("too short")))
+	a.NoError(provider.SetSessionEncryptionKeys(oldKey))
+
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*google.Session)
+	s.RefreshToken = "refresh-1234567890"
+
+	data := s.Marshal()
+	a.NotContains(data, "refresh-1234567890")
+
+	// rotate the key; sessions sealed with the old key must still open
+	a.NoError(provider.SetSessionEncryptionKeys(newKey, oldKey))
+	restored, err := provider.UnmarshalSession(data)
+	a.NoError(err)
+	a.Equal("refresh-1234567890", restored.(*google.Session).RefreshToken)
+
+	// ...and are re-sealed with the new key
+	rotated := restored.Marshal()
+	a.NoError(provider.SetSessionEncryptionKeys(newKey))
+	_, err = provider.UnmarshalSession(data)
+	a.Error(err)
+	_, err = provider.UnmarshalSession(rotated)
+	a.NoError(err)
+
+	// encrypted sessions can't be read without keys
+	_, err = googleProvider().UnmarshalSession(rotated)
+	a.Error(err)
+}
+
+func Test_FetchUserResponseTooLarge(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id":"1234","name":"%s"}`, strings.Repeat("a", 2048))
+	})
+	session := &google.Session{AccessToken: "1234567890"}
+
+	_, err := provider.FetchUser(session)
+	a.NoError(err)
+
+	provider.SetMaxResponseSize(1024)
+	_, err = provider.FetchUser(session)
+	a.ErrorIs(err, goth.ErrResponseTooLarge)
+}
+
+func Test_AuthenticatedClient(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Host {
+		case "oauth2.googleapis.com", "accounts.google.com":
+			a.Equal("refresh-token", r.FormValue("refresh_token"))
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"new-token","token_type":"Bearer","expires_in":3600}`)
+		default:
+			fmt.Fprint(w, r.Header.Get("Authorization"))
+		}
+	})
+
+	var refreshed []*oauth2.Token
+	provider.SetTokenRefreshCallback(func(token *oauth2.Token) {
+		refreshed = append(refreshed, token)
+	})
+
+	session := &google.Session{
+		AccessToken:  "old-token",
+		RefreshToken: "refresh-token",
+		ExpiresAt:    time.Now().Add(-time.Hour),
+	}
+	client := provider.AuthenticatedClient(context.Background(), session)
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get("https://www.googleapis.com/drive/v3/files")
+		a.NoError(err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		a.Equal("Bearer new-token", string(body))
+	}
+
+	a.Len(refreshed, 1)
+	a.Equal("new-token", refreshed[0].AccessToken)
+}
+
+// not parallel, so that no other test starts goroutines meanwhile
+func Test_AuthenticatedClientNoBackgroundGoroutines(t *testing.T) {
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"new-token","token_type":"Bearer","expires_in":1}`)
+		}
+	}))
+	defer ts.Close()
+
+	provider := googleProvider()
+	provider.SetEndpoint(oauth2.Endpoint{AuthURL: ts.URL + "/auth", TokenURL: ts.URL + "/token"})
+	transport := &http.Transport{}
+	provider.HTTPClient = &http.Client{Transport: transport}
+	session := &google.Session{AccessToken: "old-token", RefreshToken: "refresh-token", ExpiresAt: time.Now().Add(-time.Hour)}
+
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	client := provider.AuthenticatedClient(ctx, session)
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(ts.URL + "/drive/v3/files")
+		a.NoError(err)
+		resp.Body.Close()
+	}
+	cancel()
+	// the connections are the only goroutines left
+	transport.CloseIdleConnections()
+
+	// not a.Eventually, which checks from goroutines of its own
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	a.LessOrEqual(runtime.NumGoroutine(), before)
+}
+
+func Test_FetchUserRequireVerifiedEmail(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	verified := false
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id":"1234","email":"homer@example.com","verified_email":%t}`, verified)
+	})
+	session := &google.Session{AccessToken: "1234567890"}
+
+	_, err := provider.FetchUser(session)
+	a.NoError(err)
+
+	provider.SetRequireVerifiedEmail(true)
+	_, err = provider.FetchUser(session)
+	a.ErrorIs(err, goth.ErrEmailNotVerified)
+
+	verified = true
+	user, err := provider.FetchUser(session)
+	a.NoError(err)
+	a.Equal("homer@example.com", user.Email)
+}
+
+func Test_FetchUserReplay(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	rec, err := testsupport.New("testdata/fetch_user.json", testsupport.ModeReplay)
+	a.NoError(err)
+	provider := googleProvider()
+	provider.HTTPClient = rec.Client()
+
+	user, err := provider.FetchUser(&google.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("108204268033311374519", user.UserID)
+	a.Equal("Homer Simpson", user.Name)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("https://lh3.googleusercontent.com/a/default-user=s96-c", user.AvatarURL)
+}
+
+func Test_FetchUserAccessToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	inQuery := false
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		if inQuery {
+			a.Equal("1234567890", r.URL.Query().Get("access_token"))
+			a.Empty(r.Header.Get("Authorization"))
+		} else {
+			a.Empty(r.URL.RawQuery)
+			a.Equal("Bearer 1234567890", r.Header.Get("Authorization"))
+		}
+		fmt.Fprint(w, `{"id":"1234"}`)
+	})
+	session := &google.Session{AccessToken: "1234567890"}
+
+	_, err := provider.FetchUser(session)
+	a.NoError(err)
+
+	inQuery = true
+	provider.SetAccessTokenInQuery(true)
+	_, err = provider.FetchUser(session)
+	a.NoError(err)
+}
+
+func Test_TokensNotInURL(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := google.New(os.Getenv("GOOGLE_KEY"), os.Getenv("GOOGLE_SECRET"), "/foo", "email", google.ScopeEmailsRead)
+	requests := 0
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		a.NotContains(r.URL.String(), "secret-access-token")
+		a.NotContains(r.URL.String(), "secret-id-token")
+		a.Equal("Bearer secret-access-token", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"id":"1234","email":"homer@example.com"}`)
+	})
+
+	_, err := provider.FetchUser(&google.Session{AccessToken: "secret-access-token", IDToken: "secret-id-token"})
+	a.NoError(err)
+	// userinfo and the People API
+	a.Equal(2, requests)
+}
+
+func Test_FetchUserAvatars(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	picture := "https://lh3.googleusercontent.com/a/ACg8ocK=s96-c"
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id":"1234","picture":%q}`, picture)
+	})
+	session := &google.Session{AccessToken: "1234567890"}
+
+	user, err := provider.FetchUser(session)
+	a.NoError(err)
+	a.Equal(picture, user.AvatarURL)
+	a.Equal([]goth.Avatar{
+		{URL: "https://lh3.googleusercontent.com/a/ACg8ocK=s48-c", Size: 48},
+		{URL: "https://lh3.googleusercontent.com/a/ACg8ocK=s96-c", Size: 96},
+		{URL: "https://lh3.googleusercontent.com/a/ACg8ocK=s200-c", Size: 200},
+		{URL: "https://lh3.googleusercontent.com/a/ACg8ocK=s400-c", Size: 400},
+	}, user.Avatars)
+
+	picture = "https://example.com/homer.png"
+	user, err = provider.FetchUser(session)
+	a.NoError(err)
+	a.Equal([]goth.Avatar{{URL: picture}}, user.Avatars)
+}
+
+func Test_GrantedScopes(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := google.New(os.Getenv("GOOGLE_KEY"), os.Getenv("GOOGLE_SECRET"), "/foo", "email", google.ScopeDriveReadonly, google.ScopeCalendarEvents)
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host == "oauth2.googleapis.com" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token":"access","token_type":"Bearer","expires_in":3600,"scope":"https://www.googleapis.com/auth/userinfo.email %s"}`, google.ScopeDriveReadonly)
+			return
+		}
+		fmt.Fprint(w, `{"id":"1234"}`)
+	})
+	var denied []string
+	provider.SetDeniedScopesHandler(func(scopes []string) { denied = scopes })
+
+	session := &google.Session{}
+	_, err := session.Authorize(provider, url.Values{"code": {"code"}})
+	a.NoError(err)
+	a.Equal([]string{google.ScopeUserEmail, google.ScopeDriveReadonly}, session.GrantedScopes)
+
+	_, err = provider.FetchUser(session)
+	a.NoError(err)
+	a.Equal([]string{google.ScopeCalendarEvents}, denied)
+
+	provider.SetRequiredScopes(google.ScopeCalendarEvents)
+	_, err = provider.FetchUser(session)
+	a.ErrorIs(err, google.ErrScopesNotGranted)
+	var sng *google.ScopesNotGrantedError
+	a.ErrorAs(err, &sng)
+	a.Equal([]string{google.ScopeCalendarEvents}, sng.Missing)
+
+	provider.SetRequiredScopes("email")
+	_, err = provider.FetchUser(session)
+	a.NoError(err)
+}
+
+func Test_IncrementalAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := google.New(os.Getenv("GOOGLE_KEY"), os.Getenv("GOOGLE_SECRET"), "/foo", "email", google.ScopeDriveReadonly, google.ScopeCalendarEvents)
+	granted := []string{"openid", google.ScopeUserEmail, google.ScopeDriveReadonly}
+
+	missing := provider.MissingScopes(granted)
+	a.Equal([]string{google.ScopeCalendarEvents}, missing)
+	a.Empty(provider.MissingScopes(append(granted, google.ScopeCalendarEvents)))
+
+	session, err := provider.BeginIncrementalAuth("test_state", missing)
+	a.NoError(err)
+	authURL, err := url.Parse(session.(*google.Session).AuthURL)
+	a.NoError(err)
+	a.Equal(google.ScopeCalendarEvents, authURL.Query().Get("scope"))
+	a.Equal("true", authURL.Query().Get("include_granted_scopes"))
+	a.Equal("offline", authURL.Query().Get("access_type"))
+
+	_, err = provider.BeginIncrementalAuth("test_state", nil)
+	a.Error(err)
+
+	// the provider's own auth URL is left untouched
+	session, err = provider.BeginAuth("test_state")
+	a.NoError(err)
+	a.NotContains(session.(*google.Session).AuthURL, "include_granted_scopes")
+}
+
+func Test_FetchUserContext(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	a.Implements((*goth.ContextFetcher)(nil), provider)
+	provider.HTTPClient = testsupport.MockClientFunc(func(req *http.Request) (*http.Response, error) {
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+		return httpmock.NewStringResponse(http.StatusOK, `{"id":"1234","email":"homer@example.com"}`), nil
+	})
+
+	user, err := provider.FetchUserContext(context.Background(), &google.Session{AccessToken: "access"})
+	a.NoError(err)
+	a.Equal("1234", user.UserID)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = provider.FetchUserContext(ctx, &google.Session{AccessToken: "access"})
+	a.ErrorIs(err, context.Canceled)
+}
+
+func Test_FetchUserWithResponse(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	status := http.StatusOK
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"id":"1234","email":"homer@example.com"}`)
+	})
+	session := &google.Session{AccessToken: "1234567890"}
+
+	user, code, err := provider.FetchUserWithResponse(session)
+	a.NoError(err)
+	a.Equal(http.StatusOK, code)
+	a.Equal("1234", user.UserID)
+
+	status = http.StatusUnauthorized
+	_, code, err = provider.FetchUserWithResponse(session)
+	a.Error(err)
+	a.Equal(http.StatusUnauthorized, code)
+
+	_, code, err = provider.FetchUserWithResponse(&google.Session{})
+	a.Error(err)
+	a.Equal(0, code)
+}
+
+func Test_FetchUserEmails(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := google.New(os.Getenv("GOOGLE_KEY"), os.Getenv("GOOGLE_SECRET"), "/foo", "email", google.ScopeEmailsRead)
+	peopleStatus := http.StatusOK
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host == "people.googleapis.com" {
+			a.Equal("Bearer 1234567890", r.Header.Get("Authorization"))
+			w.WriteHeader(peopleStatus)
+			fmt.Fprint(w, `{"emailAddresses":[{"metadata":{"primary":true,"verified":true},"value":"homer.simpson@example.com"},{"metadata":{"verified":true},"value":"homer@example.com"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"1234","email":"homer@example.com","verified_email":true,"hd":"example.com"}`)
+	})
+	session := &google.Session{AccessToken: "1234567890"}
+
+	user, err := provider.FetchUser(session)
+	a.NoError(err)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("example.com", user.RawData["hd"])
+	a.Equal([]google.Email{
+		{Value: "homer.simpson@example.com", Primary: true, Verified: true},
+		{Value: "homer@example.com", Verified: true},
+	}, user.RawData["emails"])
+
+	// the user can still be stored in a session
+	var buf bytes.Buffer
+	a.NoError(gob.NewEncoder(&buf).Encode(user))
+	var decoded goth.User
+	a.NoError(gob.NewDecoder(&buf).Decode(&decoded))
+	a.Equal(user.RawData["emails"], decoded.RawData["emails"])
+
+	// without access to the People API, only the userinfo email is listed
+	peopleStatus = http.StatusForbidden
+	user, err = provider.FetchUser(session)
+	a.NoError(err)
+	a.Equal([]google.Email{
+		{Value: "homer@example.com", Primary: true, Verified: true},
+	}, user.RawData["emails"])
+
+	// without the scope, the People API is not called
+	provider = googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.NotEqual("people.googleapis.com", r.URL.Host)
+		fmt.Fprint(w, `{"id":"1234","email":"homer@example.com"}`)
+	})
+	user, err = provider.FetchUser(session)
+	a.NoError(err)
+	a.NotContains(user.RawData, "emails")
+}
+
+func Test_FetchUserRawDataTransform(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"1234","email":"homer@example.com","locale":"en","hd":"example.com"}`)
+	})
+	provider.SetRawDataTransform(func(data map[string]interface{}) map[string]interface{} {
+		delete(data, "locale")
+		data["domain"] = data["hd"]
+		delete(data, "hd")
+		return data
+	})
+
+	user, err := provider.FetchUser(&google.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal(map[string]interface{}{"id": "1234", "email": "homer@example.com", "domain": "example.com"}, user.RawData)
+}
+
+func Test_FetchUserRequiredFields(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"1234","name":"Homer"}`)
+	})
+	session := &google.Session{AccessToken: "1234567890"}
+
+	provider.SetRequiredFields("name")
+	_, err := provider.FetchUser(session)
+	a.NoError(err)
+
+	provider.SetRequiredFields("name", "email")
+	_, err = provider.FetchUser(session)
+	a.EqualError(err, "missing required field: email")
+	var missing *goth.MissingFieldError
+	a.ErrorAs(err, &missing)
+}
+
+func Test_FetchUserStrictDecoding(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":1234,"email":"homer@example.com","unknown":true}`)
+	})
+	session := &google.Session{AccessToken: "1234567890"}
+
+	_, err := provider.FetchUser(session)
+	a.Error(err)
+	a.NotContains(err.Error(), `field "id"`)
+
+	provider.SetStrictDecoding(true)
+	_, err = provider.FetchUser(session)
+	a.Error(err)
+	a.Contains(err.Error(), `field "id" is a JSON number, expected string`)
+
+	var typeErr *json.UnmarshalTypeError
+	a.True(errors.As(err, &typeErr))
+}
+
+func Test_FetchUserStrictDecodingAcceptsUnknownFields(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.SetStrictDecoding(true)
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"1234","email":"homer@example.com","unknown":true}`)
+	})
+
+	user, err := provider.FetchUser(&google.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("1234", user.UserID)
+	a.Equal(true, user.RawData["unknown"])
+}
+
+func Test_RefreshTokenQuotaExceeded(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":"rate_limit_exceeded"}`)
+	})
+
+	_, err := provider.RefreshToken("refresh-token")
+	a.True(errors.Is(err, google.ErrQuotaExceeded))
+
+	var qe *google.QuotaError
+	a.True(errors.As(err, &qe))
+	a.Equal(30*time.Second, qe.RetryAfter)
+
+	var re *oauth2.RetrieveError
+	a.True(errors.As(err, &re))
+}
+
+func Test_RefreshTokenInvalidGrant(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid_grant"}`)
+	})
+
+	_, err := provider.RefreshToken("refresh-token")
+	a.Error(err)
+	a.False(errors.Is(err, google.ErrQuotaExceeded))
+	a.True(errors.Is(err, google.ErrRefreshTokenInvalid))
+	a.False(errors.Is(err, google.ErrAuthCodeExpired))
+
+	var re *oauth2.RetrieveError
+	a.True(errors.As(err, &re))
+	a.Equal("invalid_grant", re.ErrorCode)
+}
+
+func Test_AuthorizeExpiredCode(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid_grant","error_description":"Bad Request"}`)
+	})
+
+	session := &google.Session{}
+	_, err := session.Authorize(provider, url.Values{"code": {"expired"}})
+	a.True(errors.Is(err, google.ErrAuthCodeExpired))
+	a.False(errors.Is(err, google.ErrRefreshTokenInvalid))
+
+	var re *oauth2.RetrieveError
+	a.True(errors.As(err, &re))
+}
+
+func Test_RefreshTokenTransientError(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `backend error`)
+	})
+
+	_, err := provider.RefreshToken("refresh-token")
+	a.Error(err)
+	a.False(errors.Is(err, google.ErrRefreshTokenInvalid))
+	a.False(errors.Is(err, google.ErrQuotaExceeded))
+}
+
+func Test_SetAllowedHostedDomains(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.SetAllowedHostedDomains("example.com", "partner.org")
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	a.NotContains(session.(*google.Session).AuthURL, "hd=")
+
+	hd := "partner.org"
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id":"1234","email":"homer@%[1]s","hd":"%[1]s"}`, hd)
+	})
+	user, err := provider.FetchUser(&google.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("partner.org", user.RawData["hd"])
+
+	hd = "other.org"
+	_, err = provider.FetchUser(&google.Session{AccessToken: "1234567890"})
+	a.Equal(google.ErrHostedDomainNotAllowed, err)
+
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"1234","email":"homer@gmail.com"}`)
+	})
+	_, err = provider.FetchUser(&google.Session{AccessToken: "1234567890"})
+	a.Equal(google.ErrHostedDomainNotAllowed, err)
+}
+
+func Test_SetAllowedHostedDomainsSingle(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.SetAllowedHostedDomains("example.com")
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*google.Session).AuthURL, "hd=example.com")
+
+	// calling it again replaces the domain rather than adding another hd
+	provider.SetAllowedHostedDomains("partner.org")
+	session, err = provider.BeginAuth("test_state")
+	a.NoError(err)
+	a.Equal(1, strings.Count(session.(*google.Session).AuthURL, "hd="))
+	a.Contains(session.(*google.Session).AuthURL, "hd=partner.org")
+
+	provider.SetAllowedHostedDomains("example.com", "partner.org")
+	session, err = provider.BeginAuth("test_state")
+	a.NoError(err)
+	a.NotContains(session.(*google.Session).AuthURL, "hd=")
+}
+
+func Test_NewAuthOnly(t *testing.T) {
+	// not parallel: the key set is cached process wide
+	a := assert.New(t)
+
+	sign, keys := testSigningKey(a)
+	provider := google.NewAuthOnly("client", "secret", "/foo", "email")
+	a.False(provider.RefreshTokenAvailable())
+
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*google.Session)
+	a.Contains(s.AuthURL, "scope=openid+email")
+	a.NotContains(s.AuthURL, "access_type=offline")
+
+	claims := jwt.MapClaims{
+		"iss":            "https://accounts.google.com",
+		"aud":            "client",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"sub":            "1234",
+		"email":          "homer@example.com",
+		"email_verified": true,
+		"name":           "Homer Simpson",
+	}
+	idToken := sign(claims)
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth2/v3/certs" {
+			w.Write(keys)
+			return
+		}
+		a.NotEqual("/oauth2/v2/userinfo", r.URL.Path, "userinfo must not be called")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"access","refresh_token":"refresh","token_type":"Bearer","expires_in":3600,"id_token":%q}`, idToken)
+	})
+
+	_, err = s.Authorize(provider, url.Values{"code": {"code"}})
+	a.NoError(err)
+	a.Empty(s.AccessToken)
+	a.Empty(s.RefreshToken)
+	a.Equal(idToken, s.IDToken)
+	a.True(s.IsAuthorized())
+
+	provider.SetRequireVerifiedEmail(true)
+	user, err := provider.FetchUser(s)
+	a.NoError(err)
+	a.Equal("1234", user.UserID)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("Homer Simpson", user.Name)
+	a.Empty(user.AccessToken)
+
+	// ID tokens that are unsigned, issued to another client or expired are
+	// refused
+	forged := testIDToken(map[string]interface{}(claims))
+	otherClient := jwt.MapClaims{}
+	expired := jwt.MapClaims{}
+	for k, v := range claims {
+		otherClient[k] = v
+		expired[k] = v
+	}
+	otherClient["aud"] = "other-client"
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	for _, idToken := range []string{forged, sign(otherClient), sign(expired)} {
+		_, err = provider.FetchUser(&google.Session{IDToken: idToken})
+		a.Error(err)
+	}
+}
+
+func Test_NewAuthOnlyWithoutIDToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := google.NewAuthOnly(os.Getenv("GOOGLE_KEY"), os.Getenv("GOOGLE_SECRET"), "/foo")
+	_, err := provider.FetchUser(&google.Session{})
+	a.Error(err)
+}
+
+func Test_IDTokenAudienceShapes(t *testing.T) {
+	// not parallel: the key set is cached process wide
+	a := assert.New(t)
+
+	sign, keys := testSigningKey(a)
+	provider := google.NewAuthOnly("client", "secret", "/foo")
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(keys)
+	})
+	for _, aud := range []interface{}{"client", []string{"client", "other-client"}} {
+		idToken := sign(jwt.MapClaims{
+			"iss":   "https://accounts.google.com",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"sub":   "1234",
+			"aud":   aud,
+			"email": "homer@example.com",
+		})
+		user, err := provider.FetchUser(&google.Session{IDToken: idToken})
+		a.NoError(err)
+		a.Equal("1234", user.UserID)
+		a.Equal("homer@example.com", user.Email)
+	}
+}
+
+func Test_SetTokenParams(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.SetTokenParams(oauth2.SetAuthURLParam("code_verifier", "verifier"))
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("verifier", r.FormValue("code_verifier"))
+		a.Equal("code", r.FormValue("code"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"access","token_type":"Bearer","expires_in":3600,"id_token":"id"}`)
+	})
+
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	a.NotContains(session.(*google.Session).AuthURL, "code_verifier")
+
+	_, err = session.Authorize(provider, url.Values{"code": {"code"}})
+	a.NoError(err)
+}
+
+func Test_FetchUserAndToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"1234","email":"homer@example.com"}`)
+	})
+
+	expiry := time.Now().Add(time.Hour)
+	session := &google.Session{AccessToken: "access", RefreshToken: "refresh", ExpiresAt: expiry, IDToken: "id"}
+	user, token, err := provider.FetchUserAndToken(session)
+	a.NoError(err)
+	a.Equal("1234", user.UserID)
+	a.Equal("access", token.AccessToken)
+	a.Equal("refresh", token.RefreshToken)
+	a.Equal("Bearer", token.TokenType)
+	a.Equal(expiry, token.Expiry)
+	a.Equal("id", token.Extra("id_token"))
+
+	_, token, err = provider.FetchUserAndToken(&google.Session{})
+	a.Error(err)
+	a.Nil(token)
+}
+
+func Test_LogoutURL(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	_, err := googleProvider().LogoutURL("id-token", "http://localhost/")
+	a.Equal(goth.ErrLogoutNotSupported, err)
+}
+
+func Test_Revoke(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("oauth2.googleapis.com", r.URL.Host)
+		a.Equal("/revoke", r.URL.Path)
+		if r.FormValue("token") != "refresh-token" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+
+	a.NoError(provider.Revoke("refresh-token"))
+	a.Error(provider.Revoke("unknown"))
+}
+
+func Test_SetUserCache(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	calls := 0
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprintf(w, `{"id":"%s","email":"homer@example.com","groups":["admins"]}`, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	})
+	provider.SetUserCache(time.Minute, 1)
+
+	user, err := provider.FetchUser(&google.Session{AccessToken: "a"})
+	a.NoError(err)
+	user.RawData["id"] = "modified"
+	user.RawData["groups"].([]interface{})[0] = "modified"
+
+	user, err = provider.FetchUser(&google.Session{AccessToken: "a"})
+	a.NoError(err)
+	a.Equal("a", user.UserID)
+	a.Equal("a", user.RawData["id"])
+	a.Equal([]interface{}{"admins"}, user.RawData["groups"])
+	a.Equal(1, calls)
+
+	// the cache holds a single user, b evicts a
+	user, err = provider.FetchUser(&google.Session{AccessToken: "b"})
+	a.NoError(err)
+	a.Equal("b", user.UserID)
+	_, err = provider.FetchUser(&google.Session{AccessToken: "a"})
+	a.NoError(err)
+	a.Equal(3, calls)
+
+	provider.SetUserCache(0, 0)
+	_, err = provider.FetchUser(&google.Session{AccessToken: "a"})
+	a.NoError(err)
+	a.Equal(4, calls)
+}
+
+func Test_SetRequestHeaders(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	headers := map[string]string{"X-Api-Key": "secret"}
+	provider.SetRequestHeaders(headers)
+	headers["X-Api-Key"] = "changed"
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("secret", r.Header.Get("X-Api-Key"))
+		fmt.Fprint(w, `{"id":"1234"}`)
+	})
+
+	_, err := provider.FetchUser(&google.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+}
+
+func Test_SetCallbackURL(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	a.NoError(provider.SetCallbackURL("https://staging.example.com/auth/google/callback"))
+	a.Equal("https://staging.example.com/auth/google/callback", provider.CallbackURL)
+
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*google.Session).AuthURL, "redirect_uri=https%3A%2F%2Fstaging.example.com%2Fauth%2Fgoogle%2Fcallback")
+
+	a.Error(provider.SetCallbackURL("/auth/google/callback"))
+	a.Error(provider.SetCallbackURL("https://"))
+	a.Equal("https://staging.example.com/auth/google/callback", provider.CallbackURL)
+}
+
+func Test_CallbackURLField(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.CallbackURL = "https://prod.example.com/callback"
+
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	authURL, err := url.Parse(session.(*google.Session).AuthURL)
+	a.NoError(err)
+	a.Equal("https://prod.example.com/callback", authURL.Query().Get("redirect_uri"))
+
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("https://prod.example.com/callback", r.FormValue("redirect_uri"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"access","token_type":"Bearer","expires_in":3600,"id_token":"id"}`)
+	})
+	_, err = session.Authorize(provider, url.Values{"code": {"code"}})
+	a.NoError(err)
+}
+
+func Test_EmptyCallbackURL(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := google.New(os.Getenv("GOOGLE_KEY"), os.Getenv("GOOGLE_SECRET"), "")
+
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	authURL, err := url.Parse(session.(*google.Session).AuthURL)
+	a.NoError(err)
+	_, ok := authURL.Query()["redirect_uri"]
+	a.False(ok)
+
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.NoError(r.ParseForm())
+		_, ok := r.PostForm["redirect_uri"]
+		a.False(ok)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"access","token_type":"Bearer","expires_in":3600,"id_token":"id"}`)
+	})
+	_, err = session.Authorize(provider, url.Values{"code": {"code"}})
+	a.NoError(err)
+}
+
+func Test_ScopeFieldMap(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	m := provider.ScopeFieldMap()
+	a.Equal([]string{"Email"}, m["email"])
+	a.Equal([]string{"Email"}, m["https://www.googleapis.com/auth/userinfo.email"])
+	a.Contains(m["profile"], "AvatarURL")
+	a.Contains(m["openid"], "UserID")
+
+	m["email"][0] = "changed"
+	a.Equal([]string{"Email"}, provider.ScopeFieldMap()["email"])
+}
+
+func Test_FetchUsers(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var inFlight, maxInFlight int32
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "revoked" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":%q}`, token)
+	})
+
+	sessions := []goth.Session{}
+	for _, token := range []string{"1", "2", "revoked", "4", "5", "6"} {
+		sessions = append(sessions, &google.Session{AccessToken: token})
+	}
+	results := provider.FetchUsers(context.Background(), sessions, 2)
+	a.Len(results, len(sessions))
+	for i, result := range results {
+		if i == 2 {
+			a.Error(result.Err)
+			a.Equal(http.StatusUnauthorized, result.StatusCode)
+			continue
+		}
+		a.NoError(result.Err)
+		a.Equal(http.StatusOK, result.StatusCode)
+		a.Equal(sessions[i].(*google.Session).AccessToken, result.User.UserID)
+	}
+	a.LessOrEqual(atomic.LoadInt32(&maxInFlight), int32(2))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, result := range provider.FetchUsers(ctx, sessions, 2) {
+		a.ErrorIs(result.Err, context.Canceled)
+	}
+}
+
+func Test_FetchUsersQuota(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var calls int32
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "throttled" {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":%q}`, token)
+	})
+
+	sessions := []goth.Session{}
+	for _, token := range []string{"1", "throttled", "3", "4"} {
+		sessions = append(sessions, &google.Session{AccessToken: token})
+	}
+	results := provider.FetchUsers(context.Background(), sessions, 1)
+	a.NoError(results[0].Err)
+	a.Equal(http.StatusTooManyRequests, results[1].StatusCode)
+	var qe *google.QuotaError
+	a.ErrorAs(results[1].Err, &qe)
+	a.Equal(30*time.Second, qe.RetryAfter)
+	for _, result := range results[2:] {
+		a.ErrorIs(result.Err, google.ErrQuotaExceeded)
+		a.Equal(0, result.StatusCode)
+	}
+	a.Equal(int32(2), atomic.LoadInt32(&calls))
+}
+
+func Test_FetchUsersCancelsInFlight(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClientFunc(func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	results := provider.FetchUsers(ctx, []goth.Session{&google.Session{AccessToken: "a"}, &google.Session{AccessToken: "b"}}, 2)
+	for _, result := range results {
+		a.ErrorIs(result.Err, context.DeadlineExceeded)
+	}
+}
+
+func Test_SetUserAgent(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.SetUserAgent("app/1.0")
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("app/1.0", r.Header.Get("User-Agent"))
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Host == "oauth2.googleapis.com" {
+			fmt.Fprint(w, `{"access_token":"access","token_type":"Bearer","expires_in":3600}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"1234"}`)
+	})
+
+	session, err := provider.ExchangeCode(context.Background(), "code")
+	a.NoError(err)
+	user, err := provider.FetchUser(session)
+	a.NoError(err)
+	a.Equal("1234", user.UserID)
+}
+
+func Test_SetTokenEndpointAuthMethod(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := google.New("client", "secret", "/foo")
+	a.Error(provider.SetTokenEndpointAuthMethod("private_key_jwt"))
+
+	for method, basic := range map[string]bool{"basic": true, "post": false} {
+		a.NoError(provider.SetTokenEndpointAuthMethod(method))
+		provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+			user, pass, ok := r.BasicAuth()
+			a.Equal(basic, ok, method)
+			if basic {
+				a.Equal("client", user)
+				a.Equal("secret", pass)
+				a.Empty(r.FormValue("client_secret"))
+			} else {
+				a.Equal("secret", r.FormValue("client_secret"))
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"access","token_type":"Bearer","expires_in":3600}`)
+		})
+		_, err := provider.ExchangeCode(context.Background(), "code")
+		a.NoError(err)
+		_, err = provider.RefreshToken("refresh")
+		a.NoError(err)
+	}
+}
+
+func Test_TokenScopes(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("/tokeninfo", r.URL.Path)
+		a.Empty(r.URL.RawQuery)
+		w.Header().Set("Content-Type", "application/json")
+		if r.FormValue("access_token") != "access" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_token","error_description":"Invalid Value"}`)
+			return
+		}
+		fmt.Fprint(w, `{"azp":"client","aud":"client","scope":"openid https://www.googleapis.com/auth/userinfo.email","expires_in":"3599"}`)
+	})
+
+	scopes, err := provider.TokenScopes(context.Background(), "access")
+	a.NoError(err)
+	a.Equal([]string{"openid", google.ScopeUserEmail}, scopes)
+
+	_, err = provider.TokenScopes(context.Background(), "revoked")
+	a.ErrorIs(err, google.ErrInvalidAccessToken)
+}
+
+func Test_SetMaxAge(t *testing.T) {
+	// not parallel: the key set is cached process wide
+	a := assert.New(t)
+
+	sign, keys := testSigningKey(a)
+	provider := google.NewAuthOnly("client", "secret", "/foo")
+	provider.SetMaxAge(300)
+
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*google.Session).AuthURL, "max_age=300")
+
+	var idToken string
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth2/v3/certs" {
+			w.Write(keys)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"access","token_type":"Bearer","expires_in":3600,"id_token":%q}`, idToken)
+	})
+	authorize := func(authTime interface{}) (*google.Session, error) {
+		idToken = sign(jwt.MapClaims{
+			"iss":       "https://accounts.google.com",
+			"aud":       "client",
+			"exp":       time.Now().Add(time.Hour).Unix(),
+			"sub":       "1234",
+			"auth_time": authTime,
+		})
+		s := &google.Session{}
+		_, err := s.Authorize(provider, url.Values{"code": {"code"}})
+		return s, err
+	}
+	s, err := authorize(time.Now().Add(-time.Minute).Unix())
+	a.NoError(err)
+	_, err = authorize(time.Now().Add(-time.Hour).Unix())
+	a.ErrorIs(err, goth.ErrReauthRequired)
+	_, err = authorize(nil)
+	a.ErrorIs(err, goth.ErrReauthRequired)
+
+	// within the clock skew leeway
+	_, err = authorize(time.Now().Add(-305 * time.Second).Unix())
+	a.NoError(err)
+
+	// a stored session keeps working after max_age has passed
+	provider.SetMaxAge(1)
+	provider.SetClockSkewLeeway(0)
+	_, err = provider.FetchUser(s)
+	a.NoError(err)
+}
+
+func Test_ScopesFor(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.Empty(google.ScopesFor(google.Features{}))
+	a.Equal([]string{
+		"https://www.googleapis.com/auth/gmail.readonly",
+		"https://www.googleapis.com/auth/calendar",
+	}, google.ScopesFor(google.Features{Gmail: google.ReadOnly, Calendar: google.ReadWrite}))
+	a.Equal([]string{
+		"https://www.googleapis.com/auth/gmail.modify",
+		"https://www.googleapis.com/auth/calendar.readonly",
+		"https://www.googleapis.com/auth/drive",
+		"https://www.googleapis.com/auth/spreadsheets.readonly",
+		"https://www.googleapis.com/auth/contacts",
+	}, google.ScopesFor(google.Features{
+		Gmail:    google.ReadWrite,
+		Calendar: google.ReadOnly,
+		Drive:    google.ReadWrite,
+		Sheets:   google.ReadOnly,
+		Contacts: google.ReadWrite,
+	}))
+}
+
+func Test_RefreshTokenContext(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer ts.Close()
+	// runs first, so that Close does not wait for the handler
+	defer close(release)
+
+	provider := googleProvider()
+	provider.SetEndpoint(oauth2.Endpoint{AuthURL: ts.URL + "/auth", TokenURL: ts.URL + "/token"})
+	a.Implements((*goth.ContextRefresher)(nil), provider)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := goth.RefreshTokenContext(ctx, provider, "refresh")
+	a.ErrorIs(err, context.Canceled)
+	a.Less(time.Since(start), 5*time.Second)
+}
+
+func Test_RefreshTokenWithScopes(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var form url.Values
+	provider := google.New("client", "secret", "/foo")
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.NoError(r.ParseForm())
+		form = r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(form.Get("scope"), "gmail") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_scope","error_description":"Some requested scopes were invalid."}`)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":"narrow","token_type":"Bearer","expires_in":3600,"scope":%q}`, form.Get("scope"))
+	})
+
+	token, err := provider.RefreshTokenWithScopes(context.Background(), "refresh", google.ScopeCalendarReadonly, google.ScopeDriveReadonly)
+	a.NoError(err)
+	a.Equal("refresh_token", form.Get("grant_type"))
+	a.Equal("refresh", form.Get("refresh_token"))
+	a.Equal(google.ScopeCalendarReadonly+" "+google.ScopeDriveReadonly, form.Get("scope"))
+	a.Equal("narrow", token.AccessToken)
+	a.Equal("refresh", token.RefreshToken)
+	a.Equal(form.Get("scope"), token.Extra("scope"))
+	a.WithinDuration(time.Now().Add(time.Hour), token.Expiry, time.Minute)
+
+	// widening is refused by Google
+	_, err = provider.RefreshTokenWithScopes(context.Background(), "refresh", google.ScopeGmailModify)
+	var re *oauth2.RetrieveError
+	a.True(errors.As(err, &re))
+	a.Equal("invalid_scope", re.ErrorCode)
+
+	// no scopes: a plain refresh
+	_, err = provider.RefreshTokenWithScopes(context.Background(), "refresh")
+	a.NoError(err)
+	a.Empty(form.Get("scope"))
+}
+
+func Test_AuthorizeContext(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClientFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, req.Context().Err()
+	})
+	a.Implements((*goth.ContextAuthorizer)(nil), &google.Session{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := goth.AuthorizeContext(ctx, &google.Session{}, provider, url.Values{"code": {"code"}})
+	a.ErrorIs(err, context.Canceled)
+}
+
+func Test_ConfigureOAuth2(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.ConfigureOAuth2(func(c *oauth2.Config) {
+		c.Endpoint.AuthURL = "https://accounts.example.com/auth"
+		c.Scopes = append(c.Scopes, google.ScopeDriveFile)
+	})
+
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	authURL := session.(*google.Session).AuthURL
+	a.True(strings.HasPrefix(authURL, "https://accounts.example.com/auth?"))
+	a.Contains(authURL, url.QueryEscape(google.ScopeDriveFile))
+}
+
+func Test_IsNewGrant(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	for body, newGrant := range map[string]bool{
+		`{"access_token":"access","refresh_token":"refresh","token_type":"Bearer","expires_in":3600}`: true,
+		`{"access_token":"access","token_type":"Bearer","expires_in":3600}`:                           false,
+	} {
+		body := body
+		provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, body)
+		})
+		session, err := provider.BeginAuth("test_state")
+		a.NoError(err)
+		_, err = session.Authorize(provider, url.Values{"code": {"code"}})
+		a.NoError(err)
+		a.Equal(newGrant, session.(*google.Session).IsNewGrant())
+
+		// survives a round trip through storage
+		restored, err := provider.UnmarshalSession(session.Marshal())
+		a.NoError(err)
+		a.Equal(newGrant, restored.(*google.Session).IsNewGrant())
+	}
+}
+
+func Test_ExchangeCode(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("server-code", r.FormValue("code"))
+		a.Equal("verifier", r.FormValue("code_verifier"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"access","refresh_token":"refresh","token_type":"Bearer","expires_in":3600,"id_token":"id"}`)
+	})
+
+	session, err := provider.ExchangeCode(context.Background(), "server-code", oauth2.VerifierOption("verifier"))
+	a.NoError(err)
+	s := session.(*google.Session)
+	a.Equal("access", s.AccessToken)
+	a.Equal("refresh", s.RefreshToken)
+	a.Equal("id", s.IDToken)
+	a.True(s.IsAuthorized())
+
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid_grant"}`)
+	})
+	_, err = provider.ExchangeCode(context.Background(), "used-code")
+	a.ErrorIs(err, google.ErrAuthCodeExpired)
+}
+
+func Test_PKCE(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	a.NotContains(session.(*google.Session).AuthURL, "code_challenge")
+	a.Empty(session.(*google.Session).CodeVerifier)
+
+	a.Error(provider.SetPKCEMethod("S512"))
+
+	for _, method := range []string{google.PKCEMethodS256, google.PKCEMethodPlain} {
+		a.NoError(provider.SetPKCEMethod(method))
+		session, err := provider.BeginAuth("test_state")
+		a.NoError(err)
+		s := session.(*google.Session)
+		a.NotEmpty(s.CodeVerifier)
+
+		authURL, err := url.Parse(s.AuthURL)
+		a.NoError(err)
+		a.Equal(method, authURL.Query().Get("code_challenge_method"))
+		if method == google.PKCEMethodPlain {
+			a.Equal(s.CodeVerifier, authURL.Query().Get("code_challenge"))
+		} else {
+			a.Equal(oauth2.S256ChallengeFromVerifier(s.CodeVerifier), authURL.Query().Get("code_challenge"))
+		}
+
+		// the verifier must survive the session round trip to the callback
+		s2, err := provider.UnmarshalSession(s.Marshal())
+		a.NoError(err)
+
+		provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+			a.Equal(s.CodeVerifier, r.FormValue("code_verifier"))
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"access","token_type":"Bearer","expires_in":3600}`)
+		})
+		_, err = s2.Authorize(provider, url.Values{"code": {"code"}})
+		a.NoError(err)
+	}
+}
+
+func Test_ScopeConstants(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := google.New(os.Getenv("GOOGLE_KEY"), os.Getenv("GOOGLE_SECRET"), "/foo", google.ScopeOpenID, google.ScopeUserEmail, google.ScopeDriveReadonly, "custom")
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	authURL, err := url.Parse(session.(*google.Session).AuthURL)
+	a.NoError(err)
+	a.Equal("openid https://www.googleapis.com/auth/userinfo.email https://www.googleapis.com/auth/drive.readonly custom", authURL.Query().Get("scope"))
+	a.False(provider.NeedsConsent([]string{"openid", "email", google.ScopeDriveReadonly, "custom"}))
+}
+
+func Test_GSIButtonConfig(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := google.New("client-id", "client-secret", "https://example.com/callback", "email", "profile")
+	a.Equal(map[string]string{
+		"client_id": "client-id",
+		"login_uri": "https://example.com/callback",
+		"scope":     "email profile",
+	}, provider.GSIButtonConfig())
+
+	provider.SetAllowedHostedDomains("Example.com")
+	a.Equal("example.com", provider.GSIButtonConfig()["hd"])
+	for _, value := range provider.GSIButtonConfig() {
+		a.NotContains(value, "client-secret")
+	}
+}
+
+func Test_NonceVerification(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"1234","email":"homer@example.com"}`)
+	})
+
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	a.Empty(session.(*google.Session).Nonce)
+	a.NotContains(session.(*google.Session).AuthURL, "nonce=")
+
+	provider.SetNonceVerification(true)
+	session, err = provider.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*google.Session)
+	a.NotEmpty(s.Nonce)
+	a.Contains(s.AuthURL, "nonce="+s.Nonce)
+
+	s.AccessToken = "1234567890"
+	s.IDToken = testIDToken(map[string]interface{}{"sub": "1234", "nonce": s.Nonce})
+	_, err = provider.FetchUser(s)
+	a.NoError(err)
+
+	s.IDToken = testIDToken(map[string]interface{}{"sub": "1234", "nonce": "replayed"})
+	_, err = provider.FetchUser(s)
+	a.ErrorIs(err, goth.ErrNonceMismatch)
+}
+
+func Test_SetEndpoint(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("/token", r.URL.Path)
+		a.Equal("the-code", r.FormValue("code"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"access","refresh_token":"refresh","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer ts.Close()
+
+	provider := googleProvider()
+	provider.SetEndpoint(oauth2.Endpoint{AuthURL: ts.URL + "/auth", TokenURL: ts.URL + "/token"})
+	a.Equal(ts.URL+"/auth", provider.AuthEndpoint())
+	a.Equal(ts.URL+"/token", provider.TokenEndpoint())
+
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	authURL, err := session.GetAuthURL()
+	a.NoError(err)
+	a.True(strings.HasPrefix(authURL, ts.URL+"/auth?"))
+
+	token, err := session.Authorize(provider, url.Values{"code": {"the-code"}})
+	a.NoError(err)
+	a.Equal("access", token)
+	a.Equal("refresh", session.(*google.Session).RefreshToken)
+
+	// other providers keep the default endpoint
+	a.Equal(google.Endpoint.AuthURL, googleProvider().AuthEndpoint())
+}
+
+func Test_PreferIDToken(t *testing.T) {
+	// not parallel: the key set cached process wide would be shared with
+	// Test_MultiTenantValidator
+	a := assert.New(t)
+
+	sign, keys := testSigningKey(a)
+	provider := google.New("client", "secret", "/foo")
+	provider.SetPreferIDToken(true)
+	userinfoCalls := 0
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth2/v3/certs" {
+			w.Write(keys)
+			return
+		}
+		userinfoCalls++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1234","email":"homer@example.com","name":"Homer Simpson"}`)
+	})
+	claims := func(aud, email string) jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   "https://accounts.google.com",
+			"aud":   aud,
+			"sub":   "1234",
+			"email": email,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	user, err := provider.FetchUser(&google.Session{AccessToken: "access", IDToken: sign(claims("client", "homer@example.com"))})
+	a.NoError(err)
+	a.Equal("1234", user.UserID)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal(0, userinfoCalls)
+
+	// missing email, token issued to another client, forged token or no
+	// token at all: fall back to userinfo
+	for _, idToken := range []string{
+		sign(claims("client", "")),
+		sign(claims("other-client", "homer@example.com")),
+		testIDToken(claims("client", "homer@example.com")),
+		"",
+	} {
+		user, err := provider.FetchUser(&google.Session{AccessToken: "access", IDToken: idToken})
+		a.NoError(err)
+		a.Equal("Homer Simpson", user.Name)
+	}
+	a.Equal(4, userinfoCalls)
+}
+
+func Test_Endpoints(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	a.Equal("https://accounts.google.com/o/oauth2/auth", provider.AuthEndpoint())
+	a.Equal("https://oauth2.googleapis.com/token", provider.TokenEndpoint())
+	a.Equal("https://www.googleapis.com/oauth2/v2/userinfo", provider.UserInfoEndpoint())
+}
+
+func Test_MultiTenantValidator(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	sign, keys := testSigningKey(a)
+	validator := google.NewMultiTenantValidator(func(hd string) (*google.TenantPolicy, error) {
+		switch hd {
+		case "acme.com":
+			return &google.TenantPolicy{Audiences: []string{"acme-client"}}, nil
+		case "strict.com":
+			return &google.TenantPolicy{Audiences: []string{"strict-client"}, RequireVerifiedEmail: true}, nil
+		}
+		return nil, nil
+	})
+	validator.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("www.googleapis.com", r.URL.Host)
+		w.Write(keys)
+	})
+
+	claims := func(hd, aud string) jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   "https://accounts.google.com",
+			"aud":   aud,
+			"sub":   "1234",
+			"hd":    hd,
+			"email": "homer@" + hd,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	c, err := validator.Validate(sign(claims("acme.com", "acme-client")))
+	a.NoError(err)
+	a.Equal("1234", c.Subject)
+	a.Equal("acme.com", c.HostedDomain)
+	a.Equal("homer@acme.com", c.Email)
+
+	multi := claims("acme.com", "")
+	multi["aud"] = []string{"other-client", "acme-client"}
+	_, err = validator.Validate(sign(multi))
+	a.NoError(err)
+
+	_, err = validator.Validate(sign(claims("acme.com", "strict-client")))
+	a.Error(err)
+
+	_, err = validator.Validate(sign(claims("other.com", "acme-client")))
+	a.ErrorIs(err, google.ErrTenantNotAllowed)
+
+	_, err = validator.Validate(sign(claims("strict.com", "strict-client")))
+	a.ErrorIs(err, goth.ErrEmailNotVerified)
+
+	expired := claims("acme.com", "acme-client")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	_, err = validator.Validate(sign(expired))
+	a.Error(err)
+
+	forged := claims("acme.com", "acme-client")
+	forged["iss"] = "https://evil.example.com"
+	_, err = validator.Validate(sign(forged))
+	a.Error(err)
+
+	_, err = validator.Validate(testIDToken(claims("acme.com", "acme-client")))
+	a.Error(err)
+}
+
+func Test_Warmup(t *testing.T) {
+	// not parallel: the key set is cached process wide
+	a := assert.New(t)
+
+	_, keys := testSigningKey(a)
+	goth.DefaultMetadataCache.Delete("https://www.googleapis.com/oauth2/v3/certs")
+	provider := google.New("client", "secret", "/foo")
+	calls := 0
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("/oauth2/v3/certs", r.URL.Path)
+		calls++
+		w.Write(keys)
+	})
+
+	a.NoError(provider.Warmup(context.Background()))
+	a.NoError(provider.Warmup(context.Background()))
+	a.Equal(1, calls)
+
+	// the context bounds the request
+	goth.DefaultMetadataCache.Delete("https://www.googleapis.com/oauth2/v3/certs")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	provider.HTTPClient = testsupport.MockClientFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, req.Context().Err()
+	})
+	a.ErrorIs(provider.Warmup(ctx), context.Canceled)
+
+	// invalid key sets are not kept
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "not a key set")
+	})
+	a.Error(provider.Warmup(context.Background()))
+	_, ok := goth.DefaultMetadataCache.Get("https://www.googleapis.com/oauth2/v3/certs")
+	a.False(ok)
+}
+
+func Test_ClockSkewLeeway(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	sign, keys := testSigningKey(a)
+	validator := google.NewMultiTenantValidator(func(hd string) (*google.TenantPolicy, error) {
+		return &google.TenantPolicy{Audiences: []string{"client"}}, nil
+	})
+	a.Equal(google.DefaultClockSkewLeeway, validator.ClockSkewLeeway)
+	validator.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(keys)
+	})
+	token := func(claim string, offset time.Duration) string {
+		claims := jwt.MapClaims{
+			"iss": "https://accounts.google.com",
+			"aud": "client",
+			"sub": "1234",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		claims[claim] = time.Now().Add(offset).Unix()
+		return sign(claims)
+	}
+
+	for claim, offset := range map[string]time.Duration{"exp": -30 * time.Second, "iat": 30 * time.Second, "nbf": 30 * time.Second} {
+		_, err := validator.Validate(token(claim, offset))
+		a.NoError(err, "%s just inside the leeway", claim)
+		_, err = validator.Validate(token(claim, 3*offset))
+		a.Error(err, "%s just outside the leeway", claim)
+	}
+
+	validator.ClockSkewLeeway = 0
+	_, err := validator.Validate(token("exp", -30*time.Second))
+	a.Error(err)
+
+	// the provider falls back to userinfo when the ID token is refused
+	provider := google.New("client", "secret", "/foo")
+	provider.SetPreferIDToken(true)
+	userinfoCalls := 0
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth2/v3/certs" {
+			w.Write(keys)
+			return
+		}
+		userinfoCalls++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1234","email":"homer@example.com"}`)
+	})
+	withEmail := func(offset time.Duration) string {
+		return sign(jwt.MapClaims{
+			"iss":   "https://accounts.google.com",
+			"aud":   "client",
+			"sub":   "1234",
+			"email": "homer@example.com",
+			"exp":   time.Now().Add(offset).Unix(),
+		})
+	}
+	_, err = provider.FetchUser(&google.Session{AccessToken: "access", IDToken: withEmail(-30 * time.Second)})
+	a.NoError(err)
+	a.Equal(0, userinfoCalls)
+	provider.SetClockSkewLeeway(0)
+	_, err = provider.FetchUser(&google.Session{AccessToken: "access", IDToken: withEmail(-30 * time.Second)})
+	a.NoError(err)
+	a.Equal(1, userinfoCalls)
+}
+
+// testSigningKey returns a function signing ID tokens with a new RSA key,
+// and the JSON key set Google would serve for it.
+func testSigningKey(a *assert.Assertions) (func(jwt.MapClaims) string, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	// unique per run, the key set is cached process wide
+	kid := goth.NewNonce()
+	pub, err := jwk.New(&key.PublicKey)
+	a.NoError(err)
+	a.NoError(pub.Set(jwk.KeyIDKey, kid))
+	a.NoError(pub.Set(jwk.AlgorithmKey, "RS256"))
+	keys, err := json.Marshal(map[string]interface{}{"keys": []jwk.Key{pub}})
+	a.NoError(err)
+
+	sign := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		a.NoError(err)
+		return signed
+	}
+	return sign, keys
+}
+
+func testIDToken(claims map[string]interface{}) string {
+	payload, _ := json.Marshal(claims)
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString(payload) + ".signature"
+}
+
 func googleProvider() *google.Provider {
 	return google.New(os.Getenv("GOOGLE_KEY"), os.Getenv("GOOGEL_SECRET"), "/foo")
 }
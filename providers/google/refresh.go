@@ -0,0 +1,97 @@
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/markbates/goth"
+	"golang.org/x/oauth2"
+)
+
+// RefreshTokenWithScopes works like RefreshTokenContext, but asks for an
+// access token limited to scopes, e.g. to hand a task a token that can only
+// read the user's calendar although the refresh token also grants access to
+// their mailbox. Google only narrows: every scope must have been granted to
+// the refresh token, otherwise the refresh fails, as it cannot be used to
+// gain more access. The refresh token itself keeps all its scopes. Without
+// scopes, it behaves like RefreshTokenContext.
+func (p *Provider) RefreshTokenWithScopes(ctx context.Context, refreshToken string, scopes ...string) (*oauth2.Token, error) {
+	if len(scopes) == 0 {
+		return p.RefreshTokenContext(ctx, refreshToken)
+	}
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"scope":         {strings.Join(scopes, " ")},
+	}
+	inHeader := p.config.Endpoint.AuthStyle == oauth2.AuthStyleInHeader
+	if !inHeader {
+		form.Set("client_id", p.config.ClientID)
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+	req, err := http.NewRequest("POST", p.config.Endpoint.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if inHeader {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := goth.ReadAllLimited(resp.Body, p.maxResponseSize)
+	if err != nil {
+		return nil, err
+	}
+	var tr struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		RefreshToken     string `json:"refresh_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+		ErrorURI         string `json:"error_uri"`
+	}
+	jsonErr := json.Unmarshal(body, &tr)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// same error as RefreshTokenContext, so that refreshError classifies it
+		return nil, refreshError(&oauth2.RetrieveError{
+			Response:         resp,
+			Body:             body,
+			ErrorCode:        tr.Error,
+			ErrorDescription: tr.ErrorDescription,
+			ErrorURI:         tr.ErrorURI,
+		})
+	}
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+	token := &oauth2.Token{
+		AccessToken:  tr.AccessToken,
+		TokenType:    tr.TokenType,
+		RefreshToken: tr.RefreshToken,
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	if tr.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	return token.WithExtra(raw), nil
+}
@@ -0,0 +1,90 @@
+package google
+
+// Commonly used Google scopes. Any other scope can still be passed to New as
+// a plain string. See https://developers.google.com/identity/protocols/oauth2/scopes
+const (
+	// ScopeOpenID asks for an ID token identifying the user.
+	ScopeOpenID = "openid"
+	// ScopeUserEmail gives access to the user's primary email address.
+	ScopeUserEmail = "https://www.googleapis.com/auth/userinfo.email"
+	// ScopeUserProfile gives access to the user's name and picture.
+	ScopeUserProfile = "https://www.googleapis.com/auth/userinfo.profile"
+	// ScopeEmailsRead lets FetchUser list all the email addresses of the
+	// user, including Workspace aliases, through the People API.
+	ScopeEmailsRead = "https://www.googleapis.com/auth/user.emails.read"
+
+	ScopeCalendar               = "https://www.googleapis.com/auth/calendar"
+	ScopeCalendarReadonly       = "https://www.googleapis.com/auth/calendar.readonly"
+	ScopeCalendarEvents         = "https://www.googleapis.com/auth/calendar.events"
+	ScopeCalendarEventsReadonly = "https://www.googleapis.com/auth/calendar.events.readonly"
+
+	ScopeDrive         = "https://www.googleapis.com/auth/drive"
+	ScopeDriveReadonly = "https://www.googleapis.com/auth/drive.readonly"
+	// ScopeDriveFile only gives access to the files created or opened by
+	// the application.
+	ScopeDriveFile             = "https://www.googleapis.com/auth/drive.file"
+	ScopeDriveAppData          = "https://www.googleapis.com/auth/drive.appdata"
+	ScopeDriveMetadataReadonly = "https://www.googleapis.com/auth/drive.metadata.readonly"
+
+	ScopeGmailReadonly = "https://www.googleapis.com/auth/gmail.readonly"
+	ScopeGmailSend     = "https://www.googleapis.com/auth/gmail.send"
+	// ScopeGmailModify gives read and write access to the mailbox, without
+	// permanent deletion.
+	ScopeGmailModify = "https://www.googleapis.com/auth/gmail.modify"
+
+	ScopeSpreadsheets         = "https://www.googleapis.com/auth/spreadsheets"
+	ScopeSpreadsheetsReadonly = "https://www.googleapis.com/auth/spreadsheets.readonly"
+
+	ScopeContacts         = "https://www.googleapis.com/auth/contacts"
+	ScopeContactsReadonly = "https://www.googleapis.com/auth/contacts.readonly"
+)
+
+// Access is the level of access to a Google API requested with ScopesFor.
+type Access int
+
+// Access levels.
+const (
+	NoAccess Access = iota
+	ReadOnly
+	ReadWrite
+)
+
+// Features lists the Google APIs an application needs, see ScopesFor.
+type Features struct {
+	Gmail    Access
+	Calendar Access
+	Drive    Access
+	Sheets   Access
+	Contacts Access
+}
+
+// featureScopes maps each API to its read only and read write scopes.
+var featureScopes = []struct {
+	access    func(Features) Access
+	readOnly  string
+	readWrite string
+}{
+	{func(f Features) Access { return f.Gmail }, ScopeGmailReadonly, ScopeGmailModify},
+	{func(f Features) Access { return f.Calendar }, ScopeCalendarReadonly, ScopeCalendar},
+	{func(f Features) Access { return f.Drive }, ScopeDriveReadonly, ScopeDrive},
+	{func(f Features) Access { return f.Sheets }, ScopeSpreadsheetsReadonly, ScopeSpreadsheets},
+	{func(f Features) Access { return f.Contacts }, ScopeContactsReadonly, ScopeContacts},
+}
+
+// ScopesFor returns the scopes giving the access described by features, to
+// be passed to New along with the identity scopes, e.g.
+//
+//	scopes := google.ScopesFor(google.Features{Gmail: google.ReadOnly, Calendar: google.ReadWrite})
+//	provider := google.New(key, secret, callbackURL, append(scopes, "email")...)
+func ScopesFor(features Features) []string {
+	var scopes []string
+	for _, fs := range featureScopes {
+		switch fs.access(features) {
+		case ReadOnly:
+			scopes = append(scopes, fs.readOnly)
+		case ReadWrite:
+			scopes = append(scopes, fs.readWrite)
+		}
+	}
+	return scopes
+}
@@ -1,12 +1,14 @@
 package google
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"strings"
 	"time"
 
 	"github.com/markbates/goth"
+	"golang.org/x/oauth2"
 )
 
 // Session stores data during the auth process with Google.
@@ -16,6 +18,18 @@ type Session struct {
 	RefreshToken string
 	ExpiresAt    time.Time
 	IDToken      string
+	CodeVerifier string `json:",omitempty"`
+	// Nonce is the nonce sent with the auth request when nonce verification
+	// is on, see Provider.SetNonceVerification.
+	Nonce string `json:",omitempty"`
+	// GrantedScopes are the scopes the user granted, as reported by Google
+	// on the token exchange.
+	GrantedScopes []string `json:",omitempty"`
+	// NewGrant is set when the token exchange returned a refresh token, see
+	// IsNewGrant.
+	NewGrant bool `json:",omitempty"`
+
+	cipher *sessionCipher
 }
 
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the Google provider.
@@ -28,26 +42,120 @@ func (s Session) GetAuthURL() (string, error) {
 
 // Authorize the session with Google and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	return s.AuthorizeContext(context.Background(), provider, params)
+}
+
+// AuthorizeContext works like Authorize, but gives up once ctx is done. It
+// implements goth.ContextAuthorizer.
+func (s *Session) AuthorizeContext(ctx context.Context, provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
-	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"))
+	return s.exchange(goth.ContextWithClient(ctx, p.Client()), p, params.Get("code"))
+}
+
+// exchange trades code for tokens and stores them in the session.
+func (s *Session) exchange(ctx context.Context, p *Provider, code string, extra ...oauth2.AuthCodeOption) (string, error) {
+	opts := append(p.redirectOptions(), p.tokenOptions...)
+	if s.CodeVerifier != "" {
+		opts = append(opts, oauth2.VerifierOption(s.CodeVerifier))
+	}
+	opts = append(opts, extra...)
+	token, err := p.config.Exchange(ctx, code, opts...)
 	if err != nil {
-		return "", err
+		return "", exchangeError(err)
 	}
 
 	if !token.Valid() {
 		return "", errors.New("Invalid token received from provider")
 	}
 
+	s.ExpiresAt = token.Expiry
+	s.IDToken, _ = token.Extra("id_token").(string)
+	s.NewGrant = token.RefreshToken != ""
+	if scope, ok := token.Extra("scope").(string); ok {
+		s.GrantedScopes = strings.Fields(scope)
+	}
+	if err := p.checkAuthAge(s); err != nil {
+		return "", err
+	}
+	if p.authOnly {
+		// the tokens are not needed to authenticate the user
+		if s.IDToken == "" {
+			return "", errors.New("google: no ID token received from provider")
+		}
+		return s.IDToken, nil
+	}
 	s.AccessToken = token.AccessToken
 	s.RefreshToken = token.RefreshToken
-	s.ExpiresAt = token.Expiry
-	s.IDToken = token.Extra("id_token").(string)
 	return token.AccessToken, err
 }
 
-// Marshal the session into a string
+// IsAuthorized reports whether the session went through the token exchange,
+// that is whether it holds an access or ID token.
+func (s Session) IsAuthorized() bool {
+	return s.AccessToken != "" || s.IDToken != ""
+}
+
+// IsNewGrant reports whether Google returned a refresh token on the token
+// exchange, which it only does when the user grants access to the
+// application, that is on their first sign in or after a consent screen
+// forced with prompt=consent. It is a hint for onboarding flows, not a
+// reliable first sign in detector: it is false for providers created with
+// NewAuthOnly or when access_type=offline is not requested, and it is true
+// again for returning users whenever consent is forced or they revoked the
+// application's access. Keep track of known users to be sure.
+func (s Session) IsNewGrant() bool {
+	return s.NewGrant
+}
+
+// TokenExpiry implements goth.ExpiringSession.
+func (s Session) TokenExpiry() time.Time {
+	return s.ExpiresAt
+}
+
+// SessionTokens implements goth.TokenSession.
+func (s Session) SessionTokens() goth.SessionTokens {
+	return goth.SessionTokens{
+		AccessToken:  s.AccessToken,
+		RefreshToken: s.RefreshToken,
+		IDToken:      s.IDToken,
+		ExpiresAt:    s.ExpiresAt,
+	}
+}
+
+// Refresh uses the stored refresh token to get a new access token from Google
+// and updates the session in place. The refresh token is only replaced when
+// Google rotated it.
+func (s *Session) Refresh(provider goth.Provider) error {
+	if s.RefreshToken == "" {
+		return errors.New("google: session has no refresh token, request offline access to get one")
+	}
+	token, err := provider.RefreshToken(s.RefreshToken)
+	if err != nil {
+		return err
+	}
+	s.AccessToken = token.AccessToken
+	s.ExpiresAt = token.Expiry
+	if token.RefreshToken != "" {
+		s.RefreshToken = token.RefreshToken
+	}
+	if idToken, ok := token.Extra("id_token").(string); ok {
+		s.IDToken = idToken
+		// refreshed ID tokens carry no nonce
+		s.Nonce = ""
+	}
+	if scope, ok := token.Extra("scope").(string); ok {
+		s.GrantedScopes = strings.Fields(scope)
+	}
+	return nil
+}
+
+// Marshal the session into a string. The result is encrypted when the
+// provider has been given keys with SetSessionEncryptionKeys.
 func (s Session) Marshal() string {
 	b, _ := json.Marshal(s)
+	if s.cipher != nil {
+		return s.cipher.seal(b)
+	}
 	return string(b)
 }
 
@@ -57,7 +165,17 @@ func (s Session) String() string {
 
 // UnmarshalSession will unmarshal a JSON string into a session.
 func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
-	sess := &Session{}
+	sess := &Session{cipher: p.sessionCipher}
+	if strings.HasPrefix(data, encryptedSessionPrefix) {
+		if p.sessionCipher == nil {
+			return sess, errors.New("google: session is encrypted but no encryption keys are set")
+		}
+		b, err := p.sessionCipher.open(data)
+		if err != nil {
+			return sess, err
+		}
+		data = string(b)
+	}
 	err := json.NewDecoder(strings.NewReader(data)).Decode(sess)
 	return sess, err
 }
@@ -1,10 +1,14 @@
 package google_test
 
 import (
+	"fmt"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/google"
+	"github.com/markbates/goth/testsupport"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -46,3 +50,81 @@ func Test_String(t *testing.T) {
 
 	a.Equal(s.String(), s.Marshal())
 }
+
+func Test_IsAuthorized(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.False((&google.Session{AuthURL: "/foo"}).IsAuthorized())
+	a.True((&google.Session{AccessToken: "1234567890"}).IsAuthorized())
+	a.True((&google.Session{IDToken: "id-token"}).IsAuthorized())
+}
+
+func Test_TokenExpiry(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	expiresAt := time.Now().Add(time.Hour)
+	s := &google.Session{AccessToken: "1234567890", ExpiresAt: expiresAt}
+	a.Implements((*goth.ExpiringSession)(nil), s)
+	a.Equal(expiresAt, goth.SessionExpiry(s))
+
+	// survives a round trip through storage
+	restored, err := googleProvider().UnmarshalSession(s.Marshal())
+	a.NoError(err)
+	a.True(expiresAt.Equal(goth.SessionExpiry(restored)))
+}
+
+func Test_SessionTokens(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	expiresAt := time.Now().Add(time.Hour)
+	s := &google.Session{AccessToken: "access", RefreshToken: "refresh", IDToken: "id", ExpiresAt: expiresAt}
+	a.Implements((*goth.TokenSession)(nil), s)
+	a.Equal(goth.SessionTokens{AccessToken: "access", RefreshToken: "refresh", IDToken: "id", ExpiresAt: expiresAt}, s.SessionTokens())
+}
+
+func Test_Refresh(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := googleProvider()
+	p.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("old-refresh", r.FormValue("refresh_token"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"new-token","token_type":"Bearer","expires_in":3600,"refresh_token":"new-refresh"}`)
+	})
+
+	s := &google.Session{AccessToken: "old-token", RefreshToken: "old-refresh"}
+	a.NoError(s.Refresh(p))
+	a.Equal("new-token", s.AccessToken)
+	a.Equal("new-refresh", s.RefreshToken)
+	a.True(s.ExpiresAt.After(time.Now()))
+}
+
+func Test_Refresh_KeepsRefreshToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := googleProvider()
+	p.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"new-token","token_type":"Bearer","expires_in":3600}`)
+	})
+
+	s := &google.Session{AccessToken: "old-token", RefreshToken: "old-refresh"}
+	a.NoError(s.Refresh(p))
+	a.Equal("new-token", s.AccessToken)
+	a.Equal("old-refresh", s.RefreshToken)
+}
+
+func Test_Refresh_NoRefreshToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	s := &google.Session{AccessToken: "old-token"}
+	err := s.Refresh(googleProvider())
+	a.Error(err)
+	a.Contains(err.Error(), "no refresh token")
+}
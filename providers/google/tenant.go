@@ -0,0 +1,177 @@
+package google
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/markbates/goth"
+)
+
+const endpointCerts string = "https://www.googleapis.com/oauth2/v3/certs"
+
+// DefaultClockSkewLeeway is how far the exp, iat and nbf claims of ID tokens
+// may be off by default when verifying them, to allow for clock skew with
+// Google.
+const DefaultClockSkewLeeway = time.Minute
+
+// ErrTenantNotAllowed is returned by MultiTenantValidator.Validate when the
+// resolver has no policy for the hosted domain of the ID token.
+var ErrTenantNotAllowed = errors.New("google: tenant is not allowed")
+
+// IDTokenClaims are the claims of a Google ID token.
+type IDTokenClaims struct {
+	jwt.RegisteredClaims
+	HostedDomain  string `json:"hd"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	Picture       string `json:"picture"`
+	Nonce         string `json:"nonce"`
+}
+
+// TenantPolicy is what a tenant accepts in ID tokens.
+type TenantPolicy struct {
+	// Audiences lists the client IDs the ID token may have been issued to.
+	Audiences []string
+	// RequireVerifiedEmail rejects ID tokens whose email is not verified.
+	RequireVerifiedEmail bool
+}
+
+// TenantResolver returns the policy of the tenant owning a Workspace hosted
+// domain, or nil if the domain is not a known tenant. hd is empty for
+// consumer Google accounts.
+type TenantResolver func(hd string) (*TenantPolicy, error)
+
+// MultiTenantValidator validates Google ID tokens, e.g. obtained through
+// One Tap, for platforms serving several Workspace tenants. After checking
+// the signature, issuer and expiry of a token, it resolves the tenant from
+// the hd claim and applies that tenant's policy.
+type MultiTenantValidator struct {
+	// Resolve looks up the policy of a tenant.
+	Resolve TenantResolver
+	// HTTPClient is used to fetch Google's signing keys, which are cached
+	// through goth.DefaultMetadataCache.
+	HTTPClient *http.Client
+	// ClockSkewLeeway is how far the exp, iat and nbf claims may be off.
+	ClockSkewLeeway time.Duration
+}
+
+// NewMultiTenantValidator returns a validator looking tenants up with resolve,
+// allowing for DefaultClockSkewLeeway.
+func NewMultiTenantValidator(resolve TenantResolver) *MultiTenantValidator {
+	return &MultiTenantValidator{Resolve: resolve, ClockSkewLeeway: DefaultClockSkewLeeway}
+}
+
+// Validate verifies idToken and returns its claims. It fails with
+// ErrTenantNotAllowed when the tenant is unknown, and with
+// goth.ErrEmailNotVerified when the tenant requires a verified email.
+func (v *MultiTenantValidator) Validate(idToken string) (*IDTokenClaims, error) {
+	claims := &IDTokenClaims{}
+	if err := parseIDToken(goth.UserAgentClient(v.HTTPClient), idToken, claims, v.ClockSkewLeeway); err != nil {
+		return nil, err
+	}
+
+	policy, err := v.Resolve(claims.HostedDomain)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return nil, ErrTenantNotAllowed
+	}
+
+	audienceAllowed := false
+	for _, aud := range policy.Audiences {
+		if claims.VerifyAudience(aud, true) {
+			audienceAllowed = true
+			break
+		}
+	}
+	if !audienceAllowed {
+		return nil, errors.New("google: ID token audience is not allowed for this tenant")
+	}
+	if policy.RequireVerifiedEmail && !claims.EmailVerified {
+		return nil, goth.ErrEmailNotVerified
+	}
+	return claims, nil
+}
+
+// parseIDToken verifies the signature, issuer and expiry of idToken and
+// decodes its claims. The time claims may be off by leeway.
+func parseIDToken(client *http.Client, idToken string, claims *IDTokenClaims, leeway time.Duration) error {
+	// jwt v4 has no leeway, the time claims are checked below
+	_, err := jwt.ParseWithClaims(idToken, claims, signingKey(client), jwt.WithValidMethods([]string{"RS256"}), jwt.WithoutClaimsValidation())
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if !claims.VerifyExpiresAt(now.Add(-leeway), false) {
+		return errors.New("google: ID token is expired")
+	}
+	if !claims.VerifyIssuedAt(now.Add(leeway), false) {
+		return errors.New("google: ID token used before issued")
+	}
+	if !claims.VerifyNotBefore(now.Add(leeway), false) {
+		return errors.New("google: ID token is not valid yet")
+	}
+	if claims.Issuer != "accounts.google.com" && claims.Issuer != "https://accounts.google.com" {
+		return fmt.Errorf("google: ID token issuer %q is not Google", claims.Issuer)
+	}
+	return nil
+}
+
+// Warmup fetches Google's signing keys into goth.DefaultMetadataCache, so
+// that the first ID token verification, e.g. with SetPreferIDToken, does not
+// wait on Google. Call it at startup; calling it again is cheap while the keys
+// are cached. It does nothing when goth.DefaultMetadataCache is nil.
+func (p *Provider) Warmup(ctx context.Context) error {
+	if goth.DefaultMetadataCache == nil {
+		return nil
+	}
+	data, err := goth.FetchMetadataContext(ctx, p.Client(), endpointCerts)
+	if err != nil {
+		return err
+	}
+	if _, err := jwk.Parse(data); err != nil {
+		goth.DefaultMetadataCache.Delete(endpointCerts)
+		return fmt.Errorf("google: invalid signing keys: %w", err)
+	}
+	return nil
+}
+
+// signingKey returns a jwt.Keyfunc looking up the Google public key used to
+// sign a token. The key set is fetched again when the key is not found, as
+// Google rotates its keys.
+func signingKey(client *http.Client) jwt.Keyfunc {
+	return func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		for attempt := 0; ; attempt++ {
+			data, err := goth.FetchMetadata(client, endpointCerts)
+			if err != nil {
+				return nil, err
+			}
+			set, err := jwk.Parse(data)
+			if err == nil {
+				if key, found := set.LookupKeyID(kid); found {
+					pubKey := &rsa.PublicKey{}
+					if err := key.Raw(pubKey); err != nil {
+						return nil, err
+					}
+					return pubKey, nil
+				}
+				err = errors.New("google: could not find matching public key")
+			}
+			if attempt > 0 || goth.DefaultMetadataCache == nil {
+				return nil, err
+			}
+			goth.DefaultMetadataCache.Delete(endpointCerts)
+		}
+	}
+}
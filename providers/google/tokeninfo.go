@@ -0,0 +1,57 @@
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/markbates/goth"
+)
+
+const endpointTokenInfo string = "https://oauth2.googleapis.com/tokeninfo"
+
+// ErrInvalidAccessToken is returned by TokenScopes when Google does not know
+// the access token, because it expired, was revoked or is malformed.
+var ErrInvalidAccessToken = errors.New("google: access token is invalid or expired")
+
+// TokenScopes asks Google's tokeninfo endpoint which scopes accessToken
+// grants, so that stored tokens can be audited without calling the APIs they
+// are meant for. The token is sent in the request body, never in the URL.
+func (p *Provider) TokenScopes(ctx context.Context, accessToken string) ([]string, error) {
+	form := url.Values{"access_token": {accessToken}}
+	req, err := http.NewRequest("POST", endpointTokenInfo, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := goth.ReadAllLimited(resp.Body, p.maxResponseSize)
+	if err != nil {
+		return nil, err
+	}
+	var info struct {
+		Scope string `json:"scope"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusBadRequest && info.Error == "invalid_token" {
+		return nil, ErrInvalidAccessToken
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with a %d trying to get token information", p.providerName, resp.StatusCode)
+	}
+	return strings.Fields(info.Scope), nil
+}
@@ -0,0 +1,68 @@
+package google
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/markbates/goth"
+)
+
+// userCache keeps the users fetched by FetchUser for a short time, keyed by
+// a hash of their access token.
+type userCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[[sha256.Size]byte]userCacheEntry
+}
+
+type userCacheEntry struct {
+	user    goth.User
+	expires time.Time
+}
+
+func newUserCache(ttl time.Duration, size int) *userCache {
+	return &userCache{
+		ttl:     ttl,
+		size:    size,
+		entries: map[[sha256.Size]byte]userCacheEntry{},
+	}
+}
+
+func (c *userCache) get(accessToken string) (goth.User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := sha256.Sum256([]byte(accessToken))
+	e, ok := c.entries[key]
+	if !ok {
+		return goth.User{}, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return goth.User{}, false
+	}
+	return e.user.Clone(), true
+}
+
+func (c *userCache) set(accessToken string, user goth.User) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= c.size {
+		// make room by dropping the expired entries, or the oldest one
+		var oldest [sha256.Size]byte
+		var oldestExpiry time.Time
+		for key, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, key)
+			} else if oldestExpiry.IsZero() || e.expires.Before(oldestExpiry) {
+				oldest, oldestExpiry = key, e.expires
+			}
+		}
+		if len(c.entries) >= c.size {
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[sha256.Sum256([]byte(accessToken))] = userCacheEntry{user: user.Clone(), expires: now.Add(c.ttl)}
+}
@@ -56,7 +56,7 @@ func (p *Provider) SetName(name string) {
 
 // Client returns a pointer to http.Client setting some client fallback.
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the kakao package.
@@ -14,14 +14,18 @@ import (
 )
 
 // more details about linkedin fields:
-// User Profile and Email Address - https://docs.microsoft.com/en-gb/linkedin/consumer/integrations/self-serve/sign-in-with-linkedin
-// User Avatar - https://docs.microsoft.com/en-gb/linkedin/shared/references/v2/digital-media-asset
+// Sign In with LinkedIn using OpenID Connect - https://learn.microsoft.com/en-us/linkedin/consumer/integrations/self-serve/sign-in-with-linkedin-v2
+// Legacy User Profile and Email Address - https://docs.microsoft.com/en-gb/linkedin/consumer/integrations/self-serve/sign-in-with-linkedin
+// Legacy User Avatar - https://docs.microsoft.com/en-gb/linkedin/shared/references/v2/digital-media-asset
 
 const (
 	authURL  string = "https://www.linkedin.com/oauth/v2/authorization"
 	tokenURL string = "https://www.linkedin.com/oauth/v2/accessToken"
 
-	// userEndpoint requires scope "r_liteprofile"
+	// userInfoEndpoint requires scopes "openid profile email"
+	userInfoEndpoint string = "https://api.linkedin.com/v2/userinfo"
+
+	// userEndpoint requires the legacy scope "r_liteprofile"
 	userEndpoint string = "//api.linkedin.com/v2/me?projection=(id,firstName,lastName,profilePicture(displayImage~:playableStreams))"
 	// emailEndpoint requires scope "r_emailaddress"
 	emailEndpoint string = "//api.linkedin.com/v2/emailAddress?q=members&projection=(elements*(handle~))"
@@ -30,6 +34,10 @@ const (
 // New creates a new linkedin provider, and sets up important connection details.
 // You should always call `linkedin.New` to get a new Provider. Never try to create
 // one manually.
+// By default the provider uses Sign In with LinkedIn using OpenID Connect,
+// with the scopes "openid profile email". The deprecated profile and email
+// endpoints are still used when the legacy "r_liteprofile" or
+// "r_emailaddress" scopes are requested instead of "openid".
 func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 	p := &Provider{
 		ClientKey:    clientKey,
@@ -38,9 +46,23 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 		providerName: "linkedin",
 	}
 	p.config = newConfig(p, scopes)
+	p.legacy = isLegacy(p.config.Scopes)
 	return p
 }
 
+func isLegacy(scopes []string) bool {
+	legacy := false
+	for _, scope := range scopes {
+		switch scope {
+		case "openid":
+			return false
+		case "r_liteprofile", "r_emailaddress":
+			legacy = true
+		}
+	}
+	return legacy
+}
+
 // Provider is the implementation of `goth.Provider` for accessing Linkedin.
 type Provider struct {
 	ClientKey    string
@@ -49,6 +71,7 @@ type Provider struct {
 	HTTPClient   *http.Client
 	config       *oauth2.Config
 	providerName string
+	legacy       bool
 }
 
 // Name is the name used to retrieve this provider later.
@@ -63,7 +86,7 @@ func (p *Provider) SetName(name string) {
 
 // Client returns an HTTPClientWithFallback
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the linkedin package.
@@ -92,6 +115,10 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
+	if !p.legacy {
+		return p.fetchUserInfo(user)
+	}
+
 	// create request for user r_liteprofile
 	req, err := http.NewRequest("GET", "", nil)
 	if err != nil {
@@ -152,6 +179,54 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	return user, err
 }
 
+// fetchUserInfo reads the user from the OpenID Connect userinfo endpoint.
+func (p *Provider) fetchUserInfo(user goth.User) (goth.User, error) {
+	req, err := http.NewRequest("GET", userInfoEndpoint, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+user.AccessToken)
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return user, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
+	}
+
+	bits, err := goth.ReadAllLimited(resp.Body, goth.DefaultMaxResponseSize)
+	if err != nil {
+		return user, err
+	}
+
+	u := struct {
+		Sub        string `json:"sub"`
+		Name       string `json:"name"`
+		GivenName  string `json:"given_name"`
+		FamilyName string `json:"family_name"`
+		Picture    string `json:"picture"`
+		Email      string `json:"email"`
+	}{}
+	if err := json.Unmarshal(bits, &u); err != nil {
+		return user, err
+	}
+	// email_verified and locale are only available from RawData
+	if err := json.Unmarshal(bits, &user.RawData); err != nil {
+		return user, err
+	}
+
+	user.UserID = u.Sub
+	user.Name = u.Name
+	user.FirstName = u.GivenName
+	user.LastName = u.FamilyName
+	user.NickName = u.GivenName
+	user.AvatarURL = u.Picture
+	user.Email = u.Email
+	return user, nil
+}
+
 func userFromReader(reader io.Reader, user *goth.User) error {
 	u := struct {
 		ID        string `json:"id"`
@@ -257,7 +332,7 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 
 	if len(scopes) == 0 {
 		// add helper as new API requires the scope to be specified and these are the minimum to retrieve profile information and user's email address
-		scopes = append(scopes, "r_liteprofile", "r_emailaddress")
+		scopes = append(scopes, "openid", "profile", "email")
 	}
 
 	for _, scope := range scopes {
@@ -2,11 +2,14 @@ package linkedin_test
 
 import (
 	"fmt"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/linkedin"
+	"github.com/markbates/goth/testsupport"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -41,6 +44,61 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "scope=r_liteprofile+r_emailaddress&state")
 }
 
+func Test_BeginAuthDefaultScopes(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := linkedin.New(os.Getenv("LINKEDIN_KEY"), os.Getenv("LINKEDIN_SECRET"), "/foo")
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*linkedin.Session).AuthURL, "scope=openid+profile+email&state")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := linkedin.New(os.Getenv("LINKEDIN_KEY"), os.Getenv("LINKEDIN_SECRET"), "/foo")
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("/v2/userinfo", r.URL.Path)
+		a.Equal("Bearer 1234567890", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"sub":"782bbtaQ","name":"John Doe","given_name":"John","family_name":"Doe","picture":"https://media.licdn.com/dms/image/abc","locale":"en-US","email":"doe@email.com","email_verified":true}`)
+	})
+
+	user, err := provider.FetchUser(&linkedin.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("782bbtaQ", user.UserID)
+	a.Equal("John Doe", user.Name)
+	a.Equal("John", user.FirstName)
+	a.Equal("Doe", user.LastName)
+	a.Equal("https://media.licdn.com/dms/image/abc", user.AvatarURL)
+	a.Equal("doe@email.com", user.Email)
+	a.Equal(true, user.RawData["email_verified"])
+}
+
+func Test_FetchUserLegacy(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := linkedinProvider()
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Opaque, "/v2/me"):
+			fmt.Fprint(w, `{"id":"abc","firstName":{"localized":{"en_US":"John"},"preferredLocale":{"country":"US","language":"en"}},"lastName":{"localized":{"en_US":"Doe"},"preferredLocale":{"country":"US","language":"en"}}}`)
+		case strings.Contains(r.URL.Opaque, "/v2/emailAddress"):
+			fmt.Fprint(w, `{"elements":[{"handle~":{"emailAddress":"doe@email.com"}}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	user, err := provider.FetchUser(&linkedin.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("abc", user.UserID)
+	a.Equal("John Doe", user.Name)
+	a.Equal("doe@email.com", user.Email)
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -59,7 +59,7 @@ func (p *Provider) SetName(name string) {
 }
 
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.httpClient)
+	return goth.UserAgentClient(p.httpClient)
 }
 
 // BeginAuth asks MAILRU for an authentication end-point.
@@ -28,6 +28,7 @@ type Provider struct {
 	HTTPClient   *http.Client
 	config       *oauth2.Config
 	providerName string
+	instanceURL  string
 	authURL      string
 	tokenURL     string
 	profileURL   string
@@ -40,6 +41,19 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 	return NewCustomisedURL(clientKey, secret, callbackURL, InstanceURL, scopes...)
 }
 
+// NewForInstance creates a new Mastodon provider for the given instance domain
+// (e.g. "fosstodon.org"). Every Mastodon instance is its own OAuth server and
+// applications have to be registered on each of them, so clientKey and secret
+// must be the credentials registered on that instance.
+// When using more than one instance at a time, give each provider a distinct
+// name with SetName.
+func NewForInstance(instance, clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	if !strings.Contains(instance, "://") {
+		instance = "https://" + instance
+	}
+	return NewCustomisedURL(clientKey, secret, callbackURL, instance, scopes...)
+}
+
 // NewCustomisedURL is similar to New(...) but can be used to set custom URLs to connect to
 func NewCustomisedURL(clientKey, secret, callbackURL, instanceURL string, scopes ...string) *Provider {
 	instanceURL = fmt.Sprintf("%s/", strings.TrimSuffix(instanceURL, "/"))
@@ -51,6 +65,7 @@ func NewCustomisedURL(clientKey, secret, callbackURL, instanceURL string, scopes
 		Secret:       secret,
 		CallbackURL:  callbackURL,
 		providerName: "mastodon",
+		instanceURL:  instanceURL,
 		profileURL:   profileURL,
 	}
 	p.config = newConfig(p, authURL, tokenURL, scopes)
@@ -67,8 +82,13 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// Instance returns the base URL of the Mastodon instance this provider talks to.
+func (p *Provider) Instance() string {
+	return p.instanceURL
+}
+
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the Mastodon package.
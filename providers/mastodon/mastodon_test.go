@@ -1,6 +1,9 @@
 package mastodon_test
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -29,6 +32,41 @@ func Test_NewCustomisedURL(t *testing.T) {
 	a.Contains(s.AuthURL, "http://authURL")
 }
 
+func Test_NewForInstance(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := mastodon.NewForInstance("fosstodon.org", os.Getenv("MASTODON_KEY"), os.Getenv("MASTODON_SECRET"), "/foo", "read:accounts")
+	a.Equal("https://fosstodon.org/", p.Instance())
+
+	session, err := p.BeginAuth("test_state")
+	s := session.(*mastodon.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "https://fosstodon.org/oauth/authorize")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("/api/v1/accounts/verify_credentials", r.URL.Path)
+		a.Equal("Bearer 1234567890", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"id":"14715","username":"trwnh","display_name":"infinite love","avatar":"https://files.mastodon.social/avatar.png"}`)
+	}))
+	defer ts.Close()
+
+	p := mastodon.NewForInstance(ts.URL, os.Getenv("MASTODON_KEY"), os.Getenv("MASTODON_SECRET"), "/foo")
+	session, err := p.UnmarshalSession(`{"AccessToken":"1234567890"}`)
+	a.NoError(err)
+
+	user, err := p.FetchUser(session)
+	a.NoError(err)
+	a.Equal("14715", user.UserID)
+	a.Equal("trwnh", user.NickName)
+	a.Equal("infinite love", user.Name)
+	a.Equal("https://files.mastodon.social/avatar.png", user.AvatarURL)
+}
+
 func Test_Implements_Provider(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
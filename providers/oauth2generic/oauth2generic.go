@@ -0,0 +1,277 @@
+// Package oauth2generic implements the OAuth2 protocol for authenticating users
+// through any OAuth2 provider that is not OpenID Connect compliant.
+// The fields of the user are read from the JSON userinfo response using a
+// mapping of dotted paths, so no Go code is needed to add a new provider.
+package oauth2generic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/markbates/goth"
+	"golang.org/x/oauth2"
+)
+
+// Names of the goth.User fields that can be used as keys of the mapping.
+const (
+	UserID      = "UserID"
+	Email       = "Email"
+	Name        = "Name"
+	FirstName   = "FirstName"
+	LastName    = "LastName"
+	NickName    = "NickName"
+	Description = "Description"
+	AvatarURL   = "AvatarURL"
+	Location    = "Location"
+)
+
+// DefaultMapping is used when New is given a nil mapping.
+var DefaultMapping = map[string]string{
+	UserID:    "id",
+	Email:     "email",
+	Name:      "name",
+	NickName:  "login",
+	AvatarURL: "avatar_url",
+}
+
+// Mapper fills user from the decoded userinfo response.
+type Mapper func(data map[string]interface{}, user *goth.User) error
+
+// Provider is the implementation of `goth.Provider` for accessing a generic
+// OAuth2 provider.
+type Provider struct {
+	ClientKey    string
+	Secret       string
+	CallbackURL  string
+	HTTPClient   *http.Client
+	config       *oauth2.Config
+	providerName string
+	userInfoURL  string
+	mapper       Mapper
+	headers      map[string]string
+}
+
+// New creates a new generic OAuth2 provider and sets up important connection
+// details. mapping associates goth.User field names (see the constants of this
+// package) with the dotted path of the value in the userinfo response, e.g.
+// "data.profile.email" or "emails.0.value". Use SetName to give the provider
+// a meaningful name.
+func New(clientKey, secret, callbackURL, authURL, tokenURL, userInfoURL string, mapping map[string]string, scopes ...string) *Provider {
+	if mapping == nil {
+		mapping = DefaultMapping
+	}
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "oauth2generic",
+		userInfoURL:  userInfoURL,
+		mapper:       mapperFromPaths(mapping),
+	}
+	p.config = newConfig(p, authURL, tokenURL, scopes)
+	return p
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// SetMapper replaces the path mapping given to New with a function, for
+// responses that need more than picking values.
+func (p *Provider) SetMapper(mapper Mapper) {
+	p.mapper = mapper
+}
+
+// SetRequestHeaders sets headers sent with the userinfo request of FetchUser,
+// such as the User-Agent or client id some providers require. The
+// Authorization header is always set to the access token. The values may be
+// secrets and must never be logged.
+func (p *Provider) SetRequestHeaders(headers map[string]string) {
+	p.headers = make(map[string]string, len(headers))
+	for name, value := range headers {
+		p.headers[name] = value
+	}
+}
+
+func (p *Provider) Client() *http.Client {
+	return goth.UserAgentClient(p.HTTPClient)
+}
+
+// Debug is a no-op for the oauth2generic package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth asks the provider for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return &Session{
+		AuthURL: p.config.AuthCodeURL(state),
+	}, nil
+}
+
+// FetchUser will go to the userinfo URL and map the response into a goth.User.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	s := session.(*Session)
+	user := goth.User{
+		AccessToken:  s.AccessToken,
+		Provider:     p.Name(),
+		RefreshToken: s.RefreshToken,
+		ExpiresAt:    s.ExpiresAt,
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	req, err := http.NewRequest("GET", p.userInfoURL, nil)
+	if err != nil {
+		return user, err
+	}
+	for name, value := range p.headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.AccessToken)
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return user, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
+	}
+
+	bits, err := goth.ReadAllLimited(resp.Body, goth.DefaultMaxResponseSize)
+	if err != nil {
+		return user, err
+	}
+
+	if err := json.Unmarshal(bits, &user.RawData); err != nil {
+		return user, err
+	}
+
+	// the mapper gets numbers as json.Number so that large ids are not
+	// rounded; RawData keeps plain values, json.Number cannot be gob encoded
+	var data map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(bits))
+	d.UseNumber()
+	if err := d.Decode(&data); err != nil {
+		return user, err
+	}
+
+	err = p.mapper(data, &user)
+	return user, err
+}
+
+func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  authURL,
+			TokenURL: tokenURL,
+		},
+		Scopes: []string{},
+	}
+
+	if len(scopes) > 0 {
+		c.Scopes = append(c.Scopes, scopes...)
+	}
+	return c
+}
+
+func mapperFromPaths(mapping map[string]string) Mapper {
+	return func(data map[string]interface{}, user *goth.User) error {
+		fields := map[string]*string{
+			UserID:      &user.UserID,
+			Email:       &user.Email,
+			Name:        &user.Name,
+			FirstName:   &user.FirstName,
+			LastName:    &user.LastName,
+			NickName:    &user.NickName,
+			Description: &user.Description,
+			AvatarURL:   &user.AvatarURL,
+			Location:    &user.Location,
+		}
+		for field, path := range mapping {
+			dst, ok := fields[field]
+			if !ok {
+				return fmt.Errorf("oauth2generic: unknown user field %q in mapping", field)
+			}
+			if v, ok := Lookup(data, path); ok {
+				*dst = toString(v)
+			}
+		}
+		return nil
+	}
+}
+
+// Lookup returns the value found at the dotted path in data. Path segments
+// are object keys, or indexes for arrays, e.g. "emails.0.value".
+func Lookup(data map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = data
+	for _, segment := range strings.Split(path, ".") {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[segment]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			current = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, current != nil
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// RefreshTokenAvailable refresh token is provided by auth provider or not
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
+}
+
+// RefreshToken get new access token based on the refresh token
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	token := &oauth2.Token{RefreshToken: refreshToken}
+	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
+	newToken, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	return newToken, err
+}
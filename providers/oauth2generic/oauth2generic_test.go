@@ -0,0 +1,167 @@
+package oauth2generic_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/oauth2generic"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider("http://userinfo", nil)
+
+	a.Equal(p.ClientKey, os.Getenv("OAUTH2GENERIC_KEY"))
+	a.Equal(p.Secret, os.Getenv("OAUTH2GENERIC_SECRET"))
+	a.Equal(p.CallbackURL, "/foo")
+	a.Equal(p.Name(), "oauth2generic")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider("http://userinfo", nil))
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider("http://userinfo", nil)
+	session, err := p.BeginAuth("test_state")
+	s := session.(*oauth2generic.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "http://authURL")
+	a.Contains(s.AuthURL, "scope=read")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("Bearer 1234567890", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"data":{"id":12345678901234567,"profile":{"display_name":"Homer","picture":{"url":"http://example.com/a.png"}}},"emails":[{"value":"homer@example.com"}]}`)
+	}))
+	defer ts.Close()
+
+	p := provider(ts.URL, map[string]string{
+		oauth2generic.UserID:    "data.id",
+		oauth2generic.Name:      "data.profile.display_name",
+		oauth2generic.AvatarURL: "data.profile.picture.url",
+		oauth2generic.Email:     "emails.0.value",
+		oauth2generic.NickName:  "data.profile.missing",
+	})
+	user, err := p.FetchUser(&oauth2generic.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("12345678901234567", user.UserID)
+	a.Equal("Homer", user.Name)
+	a.Equal("http://example.com/a.png", user.AvatarURL)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("", user.NickName)
+	a.Contains(user.RawData, "data")
+
+	// gothic stores users with gob, which needs every RawData type registered
+	a.NoError(gob.NewEncoder(&bytes.Buffer{}).Encode(user))
+}
+
+func Test_FetchUserUnknownField(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"1"}`)
+	}))
+	defer ts.Close()
+
+	p := provider(ts.URL, map[string]string{"Nickname": "id"})
+	_, err := p.FetchUser(&oauth2generic.Session{AccessToken: "1234567890"})
+	a.Error(err)
+	a.Contains(err.Error(), `"Nickname"`)
+}
+
+func Test_SetMapper(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"1","full_name":"Homer Simpson"}`)
+	}))
+	defer ts.Close()
+
+	p := provider(ts.URL, nil)
+	p.SetMapper(func(data map[string]interface{}, user *goth.User) error {
+		user.UserID = data["id"].(string)
+		names := strings.SplitN(data["full_name"].(string), " ", 2)
+		user.FirstName, user.LastName = names[0], names[1]
+		return nil
+	})
+	user, err := p.FetchUser(&oauth2generic.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("1", user.UserID)
+	a.Equal("Homer", user.FirstName)
+	a.Equal("Simpson", user.LastName)
+}
+
+func Test_SetRequestHeaders(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("goth-test/1.0", r.Header.Get("User-Agent"))
+		a.Equal("client", r.Header.Get("Client-Id"))
+		a.Equal("Bearer 1234567890", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"id":"1"}`)
+	}))
+	defer ts.Close()
+
+	p := provider(ts.URL, nil)
+	p.SetRequestHeaders(map[string]string{"User-Agent": "goth-test/1.0", "Client-Id": "client", "Authorization": "ignored"})
+	user, err := p.FetchUser(&oauth2generic.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("1", user.UserID)
+}
+
+func Test_Lookup(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	data := map[string]interface{}{
+		"a": map[string]interface{}{"b": []interface{}{"x", map[string]interface{}{"c": "y"}}},
+	}
+	v, ok := oauth2generic.Lookup(data, "a.b.1.c")
+	a.True(ok)
+	a.Equal("y", v)
+
+	_, ok = oauth2generic.Lookup(data, "a.b.2")
+	a.False(ok)
+	_, ok = oauth2generic.Lookup(data, "a.b.x")
+	a.False(ok)
+	_, ok = oauth2generic.Lookup(data, "a.z")
+	a.False(ok)
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider("http://userinfo", nil)
+	session, err := p.UnmarshalSession(`{"AuthURL":"http://authURL","AccessToken":"1234567890"}`)
+	a.NoError(err)
+
+	s := session.(*oauth2generic.Session)
+	a.Equal(s.AuthURL, "http://authURL")
+	a.Equal(s.AccessToken, "1234567890")
+}
+
+func provider(userInfoURL string, mapping map[string]string) *oauth2generic.Provider {
+	return oauth2generic.New(os.Getenv("OAUTH2GENERIC_KEY"), os.Getenv("OAUTH2GENERIC_SECRET"), "/foo", "http://authURL", "http://tokenURL", userInfoURL, mapping, "read")
+}
@@ -4,6 +4,7 @@ package okta
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -62,7 +63,7 @@ func (p *Provider) SetName(name string) {
 }
 
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the okta package.
@@ -196,3 +197,12 @@ func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
 	}
 	return newToken, err
 }
+
+// Introspect asks the Okta authorization server whether the given token is
+// active, see https://developer.okta.com/docs/reference/api/oidc/#introspect
+func (p *Provider) Introspect(ctx context.Context, token string) (*goth.IntrospectionResult, error) {
+	if p.issuerURL == "" {
+		return nil, goth.ErrIntrospectionNotSupported
+	}
+	return goth.IntrospectToken(ctx, p.Client(), p.issuerURL+"/v1/introspect", p.ClientKey, p.Secret, token)
+}
@@ -1,9 +1,12 @@
 package okta_test
 
 import (
+	"context"
+	"net/http"
 	"os"
 	"testing"
 
+	"github.com/jarcoal/httpmock"
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/okta"
 	"github.com/stretchr/testify/assert"
@@ -58,6 +61,41 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(s.AccessToken, "1234567890")
 }
 
+func Test_Introspect(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	transport := httpmock.NewMockTransport()
+	p := okta.New("client", "secret", "https://example.okta.com", "/foo")
+	p.HTTPClient = &http.Client{Transport: transport}
+	endpoint := "https://example.okta.com/oauth2/default/v1/introspect"
+
+	transport.RegisterResponder("POST", endpoint, func(req *http.Request) (*http.Response, error) {
+		a.NoError(req.ParseForm())
+		a.Equal("active-token", req.PostForm.Get("token"))
+		user, pass, ok := req.BasicAuth()
+		a.True(ok)
+		a.Equal("client", user)
+		a.Equal("secret", pass)
+		return httpmock.NewStringResponse(200, `{"active":true,"sub":"00u1","client_id":"client","exp":1700000000}`), nil
+	})
+	result, err := p.Introspect(context.Background(), "active-token")
+	a.NoError(err)
+	a.True(result.Active)
+	a.Equal("00u1", result.Sub)
+	a.Equal("client", result.ClientID)
+	a.Equal(int64(1700000000), result.Exp)
+
+	transport.RegisterResponder("POST", endpoint, httpmock.NewStringResponder(200, `{"active":false}`))
+	result, err = p.Introspect(context.Background(), "revoked-token")
+	a.NoError(err)
+	a.False(result.Active)
+
+	transport.RegisterResponder("POST", endpoint, httpmock.NewStringResponder(401, `{"errorCode":"invalid_client"}`))
+	_, err = p.Introspect(context.Background(), "token")
+	a.Error(err)
+}
+
 func provider() *okta.Provider {
 	return okta.New(os.Getenv("OKTA_ID"), os.Getenv("OKTA_SECRET"), os.Getenv("OKTA_ORG_URL"), "/foo")
 }
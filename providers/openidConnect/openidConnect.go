@@ -2,13 +2,14 @@ package openidConnect
 
 import (
 	"bytes"
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -69,6 +70,11 @@ type Provider struct {
 	LocationClaims  []string
 
 	SkipUserInfoRequest bool
+
+	requireVerifiedEmail bool
+	requestHeaders       map[string]string
+	skipNonce            bool
+	maxAge               int
 }
 
 type OpenIDConfig struct {
@@ -81,6 +87,18 @@ type OpenIDConfig struct {
 	// https://openid.net/specs/openid-connect-session-1_0-17.html#OPMetadata
 	EndSessionEndpoint string `json:"end_session_endpoint,omitempty"`
 	Issuer             string `json:"issuer"`
+
+	// The introspection_endpoint is not part of OpenID Connect Discovery, but is
+	// advertised by many providers. See https://datatracker.ietf.org/doc/html/rfc8414#section-2
+	IntrospectionEndpoint string `json:"introspection_endpoint,omitempty"`
+
+	// The registration_endpoint is advertised by providers supporting dynamic
+	// client registration, see RegisterClient.
+	RegistrationEndpoint string `json:"registration_endpoint,omitempty"`
+
+	// TokenEndpointAuthMethods lists the ways the token endpoint accepts
+	// client credentials, "client_secret_basic" when empty.
+	TokenEndpointAuthMethods []string `json:"token_endpoint_auth_methods_supported,omitempty"`
 }
 
 type RefreshTokenResponse struct {
@@ -90,7 +108,7 @@ type RefreshTokenResponse struct {
 	// refresh token flow. As a result, a new ID token may not be returned in a successful
 	// response.
 	// See more: https://openid.net/specs/openid-connect-core-1_0.html#RefreshingAccessToken
-	IdToken string `json:"id_token, omitempty"`
+	IdToken string `json:"id_token,omitempty"`
 
 	// The OAuth spec defines the refresh token as an optional response field in the
 	// refresh token flow. As a result, a new refresh token may not be returned in a successful
@@ -187,7 +205,7 @@ func (p *Provider) SetName(name string) {
 }
 
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the openidConnect package.
@@ -195,13 +213,28 @@ func (p *Provider) Debug(debug bool) {}
 
 // BeginAuth asks the OpenID Connect provider for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	url := p.config.AuthCodeURL(state)
-	session := &Session{
-		AuthURL: url,
+	session := &Session{}
+	var opts []oauth2.AuthCodeOption
+	if !p.skipNonce {
+		session.Nonce = goth.NewNonce()
+		opts = append(opts, oauth2.SetAuthURLParam("nonce", session.Nonce))
+	}
+	if p.maxAge > 0 {
+		opts = append(opts, oauth2.SetAuthURLParam("max_age", strconv.Itoa(p.maxAge)))
 	}
+	session.AuthURL = p.config.AuthCodeURL(state, opts...)
 	return session, nil
 }
 
+// BeginAuthContext implements goth.ContextBeginner. The discovery document is
+// fetched by New, so this only returns ctx.Err() when ctx is already done.
+func (p *Provider) BeginAuthContext(ctx context.Context, state string) (goth.Session, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return p.BeginAuth(state)
+}
+
 // FetchUser will use the id_token and access requested information about the user.
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	sess := session.(*Session)
@@ -213,7 +246,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	}
 
 	// decode returned id token to get expiry
-	claims, err := decodeJWT(sess.IDToken)
+	standard, claims, err := goth.ParseUnverifiedClaims(sess.IDToken)
 
 	if err != nil {
 		return goth.User{}, fmt.Errorf("oauth2: error decoding JWT token: %v", err)
@@ -224,6 +257,12 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return goth.User{}, fmt.Errorf("oauth2: error validating JWT token: %v", err)
 	}
 
+	if sess.Nonce != "" {
+		if standard.Nonce != sess.Nonce {
+			return goth.User{}, goth.ErrNonceMismatch
+		}
+	}
+
 	if expiry.Before(expiresAt) {
 		expiresAt = expiry
 	}
@@ -242,9 +281,63 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	}
 
 	p.userFromClaims(claims, &user)
+
+	if p.requireVerifiedEmail && !emailVerified(claims) {
+		return user, goth.ErrEmailNotVerified
+	}
 	return user, err
 }
 
+// SetNonceVerification turns the nonce check on or off. When on, which is the
+// default, BeginAuth sends a random nonce that is kept in the session, and
+// FetchUser fails with goth.ErrNonceMismatch unless the ID token carries the
+// same nonce claim. Only turn it off for providers that do not support nonces.
+func (p *Provider) SetNonceVerification(enabled bool) {
+	p.skipNonce = !enabled
+}
+
+// SetMaxAge sends the max_age parameter, asking the provider to authenticate
+// the user again when they last did more than seconds ago, e.g. before a
+// sensitive action. The code exchange, Authorize, then fails with
+// goth.ErrReauthRequired unless the auth_time claim of the ID token is within
+// that window. Zero, the default, turns this off.
+func (p *Provider) SetMaxAge(seconds int) {
+	p.maxAge = seconds
+}
+
+// checkAuthAge returns goth.ErrReauthRequired when the ID token shows that
+// the user authenticated longer ago than allowed by SetMaxAge. It runs on the
+// code exchange only, as a stored session naturally ages past max_age.
+func (p *Provider) checkAuthAge(idToken string) error {
+	if p.maxAge <= 0 {
+		return nil
+	}
+	standard, _, err := goth.ParseUnverifiedClaims(idToken)
+	if err != nil {
+		return fmt.Errorf("oauth2: error decoding JWT token: %v", err)
+	}
+	return standard.CheckAuthAge(time.Duration(p.maxAge)*time.Second, clockSkew)
+}
+
+// SetRequireVerifiedEmail makes FetchUser fail with goth.ErrEmailNotVerified
+// when the email_verified claim is false or missing. Turn this on when
+// accounts are linked by email address. It is off by default.
+func (p *Provider) SetRequireVerifiedEmail(require bool) {
+	p.requireVerifiedEmail = require
+}
+
+// emailVerified reports whether the email_verified claim is true. Some
+// providers send it as a string rather than a boolean.
+func emailVerified(claims map[string]interface{}) bool {
+	switch v := claims[EmailVerifiedClaim].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
 // RefreshTokenAvailable refresh token is provided by auth provider or not
 func (p *Provider) RefreshTokenAvailable() bool {
 	return true
@@ -252,8 +345,14 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return p.RefreshTokenContext(context.Background(), refreshToken)
+}
+
+// RefreshTokenContext works like RefreshToken, but gives up once ctx is done.
+// It implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenContext(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
 	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(oauth2.NoContext, token)
+	ts := p.config.TokenSource(goth.ContextWithClient(ctx, p.Client()), token)
 	newToken, err := ts.Token()
 	if err != nil {
 		return nil, err
@@ -271,7 +370,9 @@ func (p *Provider) RefreshTokenWithIDToken(refreshToken string) (*RefreshTokenRe
 		"grant_type":    {"refresh_token"},
 		"refresh_token": {refreshToken},
 		"client_id":     {p.ClientKey},
-		"client_secret": {p.Secret},
+	}
+	if p.config.Endpoint.AuthStyle != oauth2.AuthStyleInHeader {
+		urlValues.Set("client_secret", p.Secret)
 	}
 	req, err := http.NewRequest("POST", p.OpenIDConfig.TokenEndpoint, strings.NewReader(urlValues.Encode()))
 	if err != nil {
@@ -279,6 +380,9 @@ func (p *Provider) RefreshTokenWithIDToken(refreshToken string) (*RefreshTokenRe
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if p.config.Endpoint.AuthStyle == oauth2.AuthStyleInHeader {
+		req.SetBasicAuth(url.QueryEscape(p.ClientKey), url.QueryEscape(p.Secret))
+	}
 
 	resp, err := p.Client().Do(req)
 	if err != nil {
@@ -304,6 +408,47 @@ func (p *Provider) RefreshTokenWithIDToken(refreshToken string) (*RefreshTokenRe
 	return refreshTokenResponse, nil
 }
 
+// SetTokenEndpointAuthMethod sets how the client credentials are sent to the
+// token endpoint on exchange and refresh, see goth.TokenEndpointAuthStyle.
+// It defaults to the method advertised in the discovery document, HTTP Basic
+// authentication being preferred when both are.
+func (p *Provider) SetTokenEndpointAuthMethod(method string) error {
+	style, err := goth.TokenEndpointAuthStyle(method)
+	if err != nil {
+		return err
+	}
+	p.config.Endpoint.AuthStyle = style
+	return nil
+}
+
+// Introspect asks the provider's introspection endpoint whether the given token
+// is active, see https://datatracker.ietf.org/doc/html/rfc7662. It returns
+// goth.ErrIntrospectionNotSupported when no introspection endpoint is known.
+func (p *Provider) Introspect(ctx context.Context, token string) (*goth.IntrospectionResult, error) {
+	return goth.IntrospectToken(ctx, p.Client(), p.OpenIDConfig.IntrospectionEndpoint, p.ClientKey, p.Secret, token)
+}
+
+// SetRequestHeaders sets headers sent with the userinfo request of FetchUser,
+// e.g. an API key required by a gateway in front of the provider. The
+// Authorization header is always set to the access token. The values may be
+// secrets and must never be logged.
+func (p *Provider) SetRequestHeaders(headers map[string]string) {
+	p.requestHeaders = make(map[string]string, len(headers))
+	for name, value := range headers {
+		p.requestHeaders[name] = value
+	}
+}
+
+// LogoutURL returns the URL of the provider's end_session_endpoint to which the
+// user is redirected to log out of the provider too, see
+// https://openid.net/specs/openid-connect-rpinitiated-1_0.html. idToken is
+// sent as id_token_hint and postLogoutRedirect, which must be registered with
+// the provider, as post_logout_redirect_uri. It returns
+// goth.ErrLogoutNotSupported when no end session endpoint is known.
+func (p *Provider) LogoutURL(idToken, postLogoutRedirect string) (string, error) {
+	return goth.EndSessionURL(p.OpenIDConfig.EndSessionEndpoint, p.ClientKey, idToken, postLogoutRedirect)
+}
+
 // validate according to standard, returns expiry
 // http://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
 func (p *Provider) validateClaims(claims map[string]interface{}) (time.Time, error) {
@@ -387,6 +532,9 @@ func (p *Provider) getUserInfo(accessToken string, claims map[string]interface{}
 // fetch and decode JSON from the given UserInfo URL
 func (p *Provider) fetchUserInfo(url, accessToken string) (map[string]interface{}, error) {
 	req, _ := http.NewRequest("GET", url, nil)
+	for name, value := range p.requestHeaders {
+		req.Header.Set(name, value)
+	}
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
 
 	resp, err := p.Client().Do(req)
@@ -401,7 +549,7 @@ func (p *Provider) fetchUserInfo(url, accessToken string) (map[string]interface{
 
 	// The UserInfo Claims MUST be returned as the members of a JSON object
 	// http://openid.net/specs/openid-connect-core-1_0.html#UserInfoResponse
-	data, err := ioutil.ReadAll(resp.Body)
+	data, err := goth.ReadAllLimited(resp.Body, goth.DefaultMaxResponseSize)
 	if err != nil {
 		return nil, err
 	}
@@ -410,17 +558,8 @@ func (p *Provider) fetchUserInfo(url, accessToken string) (map[string]interface{
 }
 
 func getOpenIDConfig(p *Provider, openIDAutoDiscoveryURL string) (*OpenIDConfig, error) {
-	res, err := p.Client().Get(openIDAutoDiscoveryURL)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return nil, fmt.Errorf("Non-success code for Discovery URL: %d", res.StatusCode)
-	}
-
-	body, err := ioutil.ReadAll(res.Body)
+	// the discovery document is shared with other providers of the same issuer
+	body, err := goth.FetchMetadata(p.Client(), openIDAutoDiscoveryURL)
 	if err != nil {
 		return nil, err
 	}
@@ -428,6 +567,9 @@ func getOpenIDConfig(p *Provider, openIDAutoDiscoveryURL string) (*OpenIDConfig,
 	openIDConfig := &OpenIDConfig{}
 	err = json.Unmarshal(body, openIDConfig)
 	if err != nil {
+		if goth.DefaultMetadataCache != nil {
+			goth.DefaultMetadataCache.Delete(openIDAutoDiscoveryURL)
+		}
 		return nil, err
 	}
 
@@ -440,8 +582,9 @@ func newConfig(provider *Provider, scopes []string, openIDConfig *OpenIDConfig)
 		ClientSecret: provider.Secret,
 		RedirectURL:  provider.CallbackURL,
 		Endpoint: oauth2.Endpoint{
-			AuthURL:  openIDConfig.AuthEndpoint,
-			TokenURL: openIDConfig.TokenEndpoint,
+			AuthURL:   openIDConfig.AuthEndpoint,
+			TokenURL:  openIDConfig.TokenEndpoint,
+			AuthStyle: discoveredAuthStyle(openIDConfig.TokenEndpointAuthMethods),
 		},
 		Scopes: []string{},
 	}
@@ -466,6 +609,22 @@ func newConfig(provider *Provider, scopes []string, openIDConfig *OpenIDConfig)
 	return c
 }
 
+// discoveredAuthStyle picks how to send the client credentials among the
+// methods advertised by the provider, preferring HTTP Basic authentication.
+// Without a supported method, oauth2 tries both.
+func discoveredAuthStyle(methods []string) oauth2.AuthStyle {
+	style := oauth2.AuthStyleAutoDetect
+	for _, method := range methods {
+		switch method {
+		case goth.TokenEndpointAuthBasic:
+			return oauth2.AuthStyleInHeader
+		case goth.TokenEndpointAuthPost:
+			style = oauth2.AuthStyleInParams
+		}
+	}
+	return style
+}
+
 func getClaimValue(data map[string]interface{}, claims []string) string {
 	for _, claim := range claims {
 		if value, ok := data[claim]; ok {
@@ -496,23 +655,6 @@ func getClaimValues(data map[string]interface{}, claims []string) []string {
 	return result
 }
 
-// decodeJWT decodes a JSON Web Token into a simple map
-// http://openid.net/specs/draft-jones-json-web-token-07.html
-func decodeJWT(jwt string) (map[string]interface{}, error) {
-	jwtParts := strings.Split(jwt, ".")
-	if len(jwtParts) != 3 {
-		return nil, errors.New("jws: invalid token received, not all parts available")
-	}
-
-	decodedPayload, err := base64.URLEncoding.WithPadding(base64.NoPadding).DecodeString(jwtParts[1])
-
-	if err != nil {
-		return nil, err
-	}
-
-	return unMarshal(decodedPayload)
-}
-
 func unMarshal(payload []byte) (map[string]interface{}, error) {
 	data := make(map[string]interface{})
 
@@ -1,14 +1,21 @@
 package openidConnect
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/testsupport"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
 )
 
 var (
@@ -63,6 +70,23 @@ func Test_NewCustomisedURL(t *testing.T) {
 	a.Equal("", provider.OpenIDConfig.EndSessionEndpoint)
 }
 
+func Test_LogoutURL(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider, _ := NewCustomisedURL("client", "secret", "http://localhost/foo",
+		"https://idp.example.com/auth", "https://idp.example.com/token", "https://idp.example.com",
+		"https://idp.example.com/userinfo", "https://idp.example.com/logout")
+
+	u, err := provider.LogoutURL("id-token", "http://localhost/")
+	a.NoError(err)
+	a.Equal("https://idp.example.com/logout?client_id=client&id_token_hint=id-token&post_logout_redirect_uri=http%3A%2F%2Flocalhost%2F", u)
+
+	provider.OpenIDConfig.EndSessionEndpoint = ""
+	_, err = provider.LogoutURL("id-token", "http://localhost/")
+	a.Equal(goth.ErrLogoutNotSupported, err)
+}
+
 func Test_BeginAuth(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -78,6 +102,21 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "scope=openid")
 }
 
+func Test_BeginAuthContext(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := openidConnectProvider()
+	session, err := provider.BeginAuthContext(context.Background(), "test_state")
+	a.NoError(err)
+	a.Contains(session.(*Session).AuthURL, "state=test_state")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = provider.BeginAuthContext(ctx, "test_state")
+	a.ErrorIs(err, context.Canceled)
+}
+
 func Test_Implements_Provider(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -85,6 +124,244 @@ func Test_Implements_Provider(t *testing.T) {
 	a.Implements((*goth.Provider)(nil), openidConnectProvider())
 }
 
+func Test_Introspect(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := openidConnectProvider()
+	a.Implements((*goth.TokenIntrospector)(nil), provider)
+
+	_, err := provider.Introspect(context.Background(), "1234567890")
+	a.ErrorIs(err, goth.ErrIntrospectionNotSupported)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("1234567890", r.FormValue("token"))
+		fmt.Fprint(w, `{"active":true,"sub":"homer"}`)
+	}))
+	defer ts.Close()
+
+	provider.OpenIDConfig.IntrospectionEndpoint = ts.URL
+	result, err := provider.Introspect(context.Background(), "1234567890")
+	a.NoError(err)
+	a.True(result.Active)
+	a.Equal("homer", result.Sub)
+}
+
+func Test_RegisterClient(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := openidConnectProvider()
+	metadata := ClientMetadata{
+		RedirectURIs:       []string{"http://localhost/foo"},
+		ClientName:         "goth",
+		InitialAccessToken: "initial",
+	}
+	_, _, err := provider.RegisterClient(context.Background(), metadata)
+	a.ErrorIs(err, ErrRegistrationNotSupported)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("POST", r.Method)
+		a.Equal("Bearer initial", r.Header.Get("Authorization"))
+		var got map[string]interface{}
+		a.NoError(json.NewDecoder(r.Body).Decode(&got))
+		a.Equal("goth", got["client_name"])
+		a.NotContains(got, "InitialAccessToken")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"client_id":"new-client","client_secret":"new-secret","client_name":"goth"}`)
+	}))
+	defer ts.Close()
+
+	provider.OpenIDConfig.RegistrationEndpoint = ts.URL
+	clientID, clientSecret, err := provider.RegisterClient(context.Background(), metadata)
+	a.NoError(err)
+	a.Equal("new-client", clientID)
+	a.Equal("new-secret", clientSecret)
+
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid_redirect_uri","error_description":"bad redirect"}`)
+	}))
+	defer rejecting.Close()
+
+	provider.OpenIDConfig.RegistrationEndpoint = rejecting.URL
+	_, _, err = provider.RegisterClient(context.Background(), metadata)
+	a.ErrorContains(err, "invalid_redirect_uri")
+}
+
+func Test_SetTokenEndpointAuthMethod(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := openidConnectProvider()
+	// the discovery document advertises both, basic is preferred
+	a.Equal(oauth2.AuthStyleInHeader, provider.config.Endpoint.AuthStyle)
+	a.Equal(oauth2.AuthStyleInParams, discoveredAuthStyle([]string{"client_secret_post"}))
+	a.Equal(oauth2.AuthStyleAutoDetect, discoveredAuthStyle(nil))
+
+	a.Error(provider.SetTokenEndpointAuthMethod("private_key_jwt"))
+	for method, basic := range map[string]bool{"basic": true, "post": false} {
+		a.NoError(provider.SetTokenEndpointAuthMethod(method))
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, secret, ok := r.BasicAuth()
+			a.Equal(basic, ok, method)
+			if !basic {
+				secret = r.FormValue("client_secret")
+			}
+			a.Equal(provider.Secret, secret)
+			fmt.Fprint(w, `{"access_token":"access","id_token":"id"}`)
+		}))
+		provider.OpenIDConfig.TokenEndpoint = ts.URL
+		_, err := provider.RefreshTokenWithIDToken("refresh")
+		a.NoError(err)
+		ts.Close()
+	}
+}
+
+func Test_SetRequestHeaders(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("secret", r.Header.Get("X-Api-Key"))
+		a.Equal("Bearer 1234567890", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"sub":"1234","email":"homer@example.com"}`)
+	}))
+	defer ts.Close()
+
+	provider := openidConnectProvider()
+	provider.OpenIDConfig.UserInfoEndpoint = ts.URL
+	provider.SetRequestHeaders(map[string]string{"X-Api-Key": "secret"})
+
+	claims := map[string]interface{}{
+		"iss": "https://accounts.google.com",
+		"aud": provider.ClientKey,
+		"sub": "1234",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	user, err := provider.FetchUser(&Session{AccessToken: "1234567890", IDToken: testIDToken(claims)})
+	a.NoError(err)
+	a.Equal("homer@example.com", user.Email)
+}
+
+func Test_FetchUserRequireVerifiedEmail(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := openidConnectProvider()
+	provider.SkipUserInfoRequest = true
+
+	claims := map[string]interface{}{
+		"iss":   "https://accounts.google.com",
+		"aud":   provider.ClientKey,
+		"sub":   "1234",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"email": "homer@example.com",
+	}
+	session := &Session{AccessToken: "1234567890", IDToken: testIDToken(claims)}
+
+	_, err := provider.FetchUser(session)
+	a.NoError(err)
+
+	provider.SetRequireVerifiedEmail(true)
+	_, err = provider.FetchUser(session)
+	a.ErrorIs(err, goth.ErrEmailNotVerified)
+
+	claims["email_verified"] = false
+	session.IDToken = testIDToken(claims)
+	_, err = provider.FetchUser(session)
+	a.ErrorIs(err, goth.ErrEmailNotVerified)
+
+	for _, verified := range []interface{}{true, "true"} {
+		claims["email_verified"] = verified
+		session.IDToken = testIDToken(claims)
+		user, err := provider.FetchUser(session)
+		a.NoError(err)
+		a.Equal("homer@example.com", user.Email)
+	}
+}
+
+func Test_Nonce(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := openidConnectProvider()
+	provider.SkipUserInfoRequest = true
+
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*Session)
+	a.NotEmpty(s.Nonce)
+	a.Contains(s.AuthURL, "nonce="+s.Nonce)
+
+	claims := map[string]interface{}{
+		"iss":   "https://accounts.google.com",
+		"aud":   provider.ClientKey,
+		"sub":   "1234",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": s.Nonce,
+	}
+	s.IDToken = testIDToken(claims)
+	_, err = provider.FetchUser(s)
+	a.NoError(err)
+
+	for _, nonce := range []interface{}{"replayed", nil} {
+		claims["nonce"] = nonce
+		s.IDToken = testIDToken(claims)
+		_, err = provider.FetchUser(s)
+		a.ErrorIs(err, goth.ErrNonceMismatch)
+	}
+
+	provider.SetNonceVerification(false)
+	session, err = provider.BeginAuth("test_state")
+	a.NoError(err)
+	a.Empty(session.(*Session).Nonce)
+	a.NotContains(session.(*Session).AuthURL, "nonce=")
+}
+
+func Test_SetMaxAge(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := openidConnectProvider()
+	provider.SkipUserInfoRequest = true
+	provider.SetNonceVerification(false)
+	provider.SetMaxAge(300)
+
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*Session).AuthURL, "max_age=300")
+
+	claims := map[string]interface{}{
+		"iss": "https://accounts.google.com",
+		"aud": provider.ClientKey,
+		"sub": "1234",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"access","token_type":"Bearer","expires_in":3600,"id_token":%q}`, testIDToken(claims))
+	})
+	authorize := func(authTime interface{}) (*Session, error) {
+		claims["auth_time"] = authTime
+		s := &Session{}
+		_, err := s.Authorize(provider, url.Values{"code": {"code"}})
+		return s, err
+	}
+
+	s, err := authorize(time.Now().Add(-time.Minute).Unix())
+	a.NoError(err)
+	for _, authTime := range []interface{}{time.Now().Add(-time.Hour).Unix(), nil} {
+		_, err = authorize(authTime)
+		a.ErrorIs(err, goth.ErrReauthRequired)
+	}
+
+	// a stored session keeps working after max_age has passed
+	provider.SetMaxAge(1)
+	_, err = provider.FetchUser(s)
+	a.NoError(err)
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -103,3 +380,10 @@ func openidConnectProvider() *Provider {
 	provider, _ := New(os.Getenv("OPENID_CONNECT_KEY"), os.Getenv("OPENID_CONNECT_SECRET"), "http://localhost/foo", server.URL)
 	return provider
 }
+
+// testIDToken builds an unsigned JWT carrying the given claims.
+func testIDToken(claims map[string]interface{}) string {
+	payload, _ := json.Marshal(claims)
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString(payload) + ".signature"
+}
@@ -0,0 +1,93 @@
+package openidConnect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/markbates/goth"
+)
+
+// ErrRegistrationNotSupported is returned by RegisterClient when the discovery
+// document advertises no registration endpoint.
+var ErrRegistrationNotSupported = errors.New("openidConnect: provider does not support dynamic client registration")
+
+// ClientMetadata describes the client to register with RegisterClient. See
+// https://datatracker.ietf.org/doc/html/rfc7591#section-2 for the meaning of
+// each field; empty fields are left to the provider's defaults.
+type ClientMetadata struct {
+	RedirectURIs            []string `json:"redirect_uris"`
+	ClientName              string   `json:"client_name,omitempty"`
+	ClientURI               string   `json:"client_uri,omitempty"`
+	LogoURI                 string   `json:"logo_uri,omitempty"`
+	Contacts                []string `json:"contacts,omitempty"`
+	GrantTypes              []string `json:"grant_types,omitempty"`
+	ResponseTypes           []string `json:"response_types,omitempty"`
+	Scope                   string   `json:"scope,omitempty"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method,omitempty"`
+
+	// InitialAccessToken is sent as a bearer token to providers that only
+	// accept registrations authorized by their administrator. It is not part
+	// of the metadata.
+	InitialAccessToken string `json:"-"`
+}
+
+// RegisterClient registers a new client with the provider through OAuth 2.0
+// dynamic client registration, see https://datatracker.ietf.org/doc/html/rfc7591,
+// and returns its credentials, which can then be used to create a provider
+// with New. The client secret is empty for public clients. It returns
+// ErrRegistrationNotSupported when the provider has no registration endpoint.
+func (p *Provider) RegisterClient(ctx context.Context, metadata ClientMetadata) (clientID, clientSecret string, err error) {
+	if p.OpenIDConfig == nil || p.OpenIDConfig.RegistrationEndpoint == "" {
+		return "", "", ErrRegistrationNotSupported
+	}
+
+	body, err := json.Marshal(metadata)
+	if err != nil {
+		return "", "", err
+	}
+	req, err := http.NewRequest("POST", p.OpenIDConfig.RegistrationEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if metadata.InitialAccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+metadata.InitialAccessToken)
+	}
+
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := goth.ReadAllLimited(resp.Body, 0)
+	if err != nil {
+		return "", "", err
+	}
+
+	var result struct {
+		ClientID         string `json:"client_id"`
+		ClientSecret     string `json:"client_secret"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		if json.Unmarshal(data, &result) == nil && result.Error != "" {
+			return "", "", fmt.Errorf("%s client registration failed: %s: %s", p.providerName, result.Error, result.ErrorDescription)
+		}
+		return "", "", fmt.Errorf("%s responded with a %d trying to register a client", p.providerName, resp.StatusCode)
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", "", err
+	}
+	if result.ClientID == "" {
+		return "", "", fmt.Errorf("%s returned no client_id on client registration", p.providerName)
+	}
+	return result.ClientID, result.ClientSecret, nil
+}
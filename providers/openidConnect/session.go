@@ -1,6 +1,7 @@
 package openidConnect
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"strings"
@@ -17,6 +18,9 @@ type Session struct {
 	RefreshToken string
 	ExpiresAt    time.Time
 	IDToken      string
+	// Nonce is the nonce sent with the auth request, that the ID token must
+	// carry. Clear it when replacing IDToken with a refreshed ID token.
+	Nonce string `json:",omitempty"`
 }
 
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the OpenID Connect provider.
@@ -29,6 +33,12 @@ func (s Session) GetAuthURL() (string, error) {
 
 // Authorize the session with the OpenID Connect provider and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	return s.AuthorizeContext(context.Background(), provider, params)
+}
+
+// AuthorizeContext works like Authorize, but gives up once ctx is done. It
+// implements goth.ContextAuthorizer.
+func (s *Session) AuthorizeContext(ctx context.Context, provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
 
 	var authParams []oauth2.AuthCodeOption
@@ -45,7 +55,7 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 		authParams = append(authParams, oauth2.SetAuthURLParam("code_verifier", codeVerifier))
 	}
 
-	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"), authParams...)
+	token, err := p.config.Exchange(goth.ContextWithClient(ctx, p.Client()), params.Get("code"), authParams...)
 	if err != nil {
 		return "", err
 	}
@@ -58,6 +68,9 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	s.RefreshToken = token.RefreshToken
 	s.ExpiresAt = token.Expiry
 	s.IDToken = token.Extra("id_token").(string)
+	if err := p.checkAuthAge(s.IDToken); err != nil {
+		return "", err
+	}
 	return token.AccessToken, err
 }
 
@@ -77,3 +90,13 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 	err := json.NewDecoder(strings.NewReader(data)).Decode(sess)
 	return sess, err
 }
+
+// SessionTokens implements goth.TokenSession.
+func (s Session) SessionTokens() goth.SessionTokens {
+	return goth.SessionTokens{
+		AccessToken:  s.AccessToken,
+		RefreshToken: s.RefreshToken,
+		IDToken:      s.IDToken,
+		ExpiresAt:    s.ExpiresAt,
+	}
+}
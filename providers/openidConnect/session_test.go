@@ -1,6 +1,8 @@
 package openidConnect
 
 import (
+	"context"
+	"net/url"
 	"testing"
 
 	"github.com/markbates/goth"
@@ -15,6 +17,19 @@ func Test_Implements_Session(t *testing.T) {
 	a.Implements((*goth.Session)(nil), s)
 }
 
+func Test_AuthorizeContext(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := openidConnectProvider()
+	a.Implements((*goth.ContextAuthorizer)(nil), &Session{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := goth.AuthorizeContext(ctx, &Session{}, provider, url.Values{"code": {"code"}})
+	a.ErrorIs(err, context.Canceled)
+}
+
 func Test_GetAuthURL(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
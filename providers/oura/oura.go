@@ -62,7 +62,7 @@ func (p *Provider) SetName(name string) {
 
 // Client for making requests on the provider
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the oura package.
@@ -9,7 +9,6 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
-	"net/url"
 	"os"
 
 	"github.com/markbates/goth"
@@ -17,18 +16,23 @@ import (
 )
 
 const (
-	sandbox string = "sandbox"
+	// EnvSandbox selects PayPal's sandbox hosts, used for development.
+	EnvSandbox string = "sandbox"
+	// EnvLive selects PayPal's production hosts.
+	EnvLive string = "live"
+
+	sandbox string = EnvSandbox
 	envKey  string = "PAYPAL_ENV"
 
 	// Endpoints for paypal sandbox env
-	authURLSandbox         string = "https://www.sandbox.paypal.com/webapps/auth/protocol/openidconnect/v1/authorize"
-	tokenURLSandbox        string = "https://www.sandbox.paypal.com/webapps/auth/protocol/openidconnect/v1/tokenservice"
-	endpointProfileSandbox string = "https://www.sandbox.paypal.com/webapps/auth/protocol/openidconnect/v1/userinfo"
+	authURLSandbox         string = "https://www.sandbox.paypal.com/signin/authorize"
+	tokenURLSandbox        string = "https://api-m.sandbox.paypal.com/v1/oauth2/token"
+	endpointProfileSandbox string = "https://api-m.sandbox.paypal.com/v1/identity/oauth2/userinfo"
 
 	// Endpoints for paypal production env
-	authURLProduction         string = "https://www.paypal.com/webapps/auth/protocol/openidconnect/v1/authorize"
-	tokenURLProduction        string = "https://www.paypal.com/webapps/auth/protocol/openidconnect/v1/tokenservice"
-	endpointProfileProduction string = "https://www.paypal.com/webapps/auth/protocol/openidconnect/v1/userinfo"
+	authURLProduction         string = "https://www.paypal.com/signin/authorize"
+	tokenURLProduction        string = "https://api-m.paypal.com/v1/oauth2/token"
+	endpointProfileProduction string = "https://api-m.paypal.com/v1/identity/oauth2/userinfo"
 )
 
 // Provider is the implementation of `goth.Provider` for accessing Paypal.
@@ -45,14 +49,20 @@ type Provider struct {
 // New creates a new Paypal provider and sets up important connection details.
 // You should always call `paypal.New` to get a new provider.  Never try to
 // create one manually.
+// The sandbox hosts are used when the PAYPAL_ENV environment variable is set
+// to "sandbox", see NewForEnv to choose them explicitly.
 func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
-	paypalEnv := os.Getenv(envKey)
+	return NewForEnv(os.Getenv(envKey), clientKey, secret, callbackURL, scopes...)
+}
 
+// NewForEnv is similar to New(...) but selects the PayPal hosts with env,
+// either EnvSandbox or EnvLive.
+func NewForEnv(env, clientKey, secret, callbackURL string, scopes ...string) *Provider {
 	authURL := authURLProduction
 	tokenURL := tokenURLProduction
 	profileEndPoint := endpointProfileProduction
 
-	if paypalEnv == sandbox {
+	if env == sandbox {
 		authURL = authURLSandbox
 		tokenURL = tokenURLSandbox
 		profileEndPoint = endpointProfileSandbox
@@ -85,7 +95,7 @@ func (p *Provider) SetName(name string) {
 }
 
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the paypal package.
@@ -113,11 +123,13 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	response, err := p.Client().Get(p.profileURL + "?schema=openid&access_token=" + url.QueryEscape(sess.AccessToken))
+	req, err := http.NewRequest("GET", p.profileURL+"?schema=openid", nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+	response, err := p.Client().Do(req)
 	if err != nil {
-		if response != nil {
-			response.Body.Close()
-		}
 		return user, err
 	}
 	defer response.Body.Close()
@@ -149,6 +161,8 @@ func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *o
 		Endpoint: oauth2.Endpoint{
 			AuthURL:  authURL,
 			TokenURL: tokenURL,
+			// the token endpoint expects the client credentials as basic auth
+			AuthStyle: oauth2.AuthStyleInHeader,
 		},
 		Scopes: []string{},
 	}
@@ -158,7 +172,7 @@ func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *o
 			c.Scopes = append(c.Scopes, scope)
 		}
 	} else {
-		c.Scopes = append(c.Scopes, "profile", "email")
+		c.Scopes = append(c.Scopes, "openid", "profile", "email")
 	}
 	return c
 }
@@ -169,14 +183,26 @@ func userFromReader(r io.Reader, user *goth.User) error {
 		Address struct {
 			Locality string `json:"locality"`
 		} `json:"address"`
-		Email string `json:"email"`
-		ID    string `json:"user_id"`
+		Email  string `json:"email"`
+		Emails []struct {
+			Value   string `json:"value"`
+			Primary bool   `json:"primary"`
+		} `json:"emails"`
+		ID string `json:"user_id"`
 	}{}
 	err := json.NewDecoder(r).Decode(&u)
 	if err != nil {
 		return err
 	}
 	user.Email = u.Email
+	if user.Email == "" {
+		// the identity API lists the addresses, prefer the primary one
+		for i, e := range u.Emails {
+			if i == 0 || e.Primary {
+				user.Email = e.Value
+			}
+		}
+	}
 	user.Name = u.Name
 	user.UserID = u.ID
 	user.Location = u.Address.Locality
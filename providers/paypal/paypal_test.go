@@ -1,6 +1,9 @@
 package paypal_test
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -42,7 +45,42 @@ func Test_BeginAuth(t *testing.T) {
 	session, err := p.BeginAuth("test_state")
 	s := session.(*paypal.Session)
 	a.NoError(err)
-	a.Contains(s.AuthURL, "paypal.com/webapps/auth/protocol/openidconnect/v1/authorize")
+	a.Contains(s.AuthURL, "https://www.paypal.com/signin/authorize")
+}
+
+func Test_NewForEnv(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := paypal.NewForEnv(paypal.EnvSandbox, os.Getenv("PAYPAL_KEY"), os.Getenv("PAYPAL_SECRET"), "/foo")
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*paypal.Session).AuthURL, "https://www.sandbox.paypal.com/signin/authorize")
+
+	p = paypal.NewForEnv(paypal.EnvLive, os.Getenv("PAYPAL_KEY"), os.Getenv("PAYPAL_SECRET"), "/foo")
+	session, err = p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*paypal.Session).AuthURL, "https://www.paypal.com/signin/authorize")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("openid", r.URL.Query().Get("schema"))
+		a.Equal("Bearer 1234567890", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"user_id":"https://www.paypal.com/webapps/auth/identity/user/mWq6_1sU85v5EG9yHdPxJRrhGHrnMJ-1PQKtX6pcsmA","name":"identity test","payer_id":"WDJJHEBZ4X2LY","emails":[{"value":"other@example.com"},{"value":"user1@example.com","primary":true}]}`)
+	}))
+	defer ts.Close()
+
+	p := paypal.NewCustomisedURL(os.Getenv("PAYPAL_KEY"), os.Getenv("PAYPAL_SECRET"), "/foo", "http://authURL", "http://tokenURL", ts.URL)
+	user, err := p.FetchUser(&paypal.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("https://www.paypal.com/webapps/auth/identity/user/mWq6_1sU85v5EG9yHdPxJRrhGHrnMJ-1PQKtX6pcsmA", user.UserID)
+	a.Equal("identity test", user.Name)
+	a.Equal("user1@example.com", user.Email)
+	a.Equal("WDJJHEBZ4X2LY", user.RawData["payer_id"])
 }
 
 func Test_SessionFromJSON(t *testing.T) {
@@ -50,11 +88,11 @@ func Test_SessionFromJSON(t *testing.T) {
 	a := assert.New(t)
 
 	p := provider()
-	session, err := p.UnmarshalSession(`{"AuthURL":"https://www.paypal.com/webapps/auth/protocol/openidconnect/v1/authorize","AccessToken":"1234567890"}`)
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://www.paypal.com/signin/authorize","AccessToken":"1234567890"}`)
 	a.NoError(err)
 
 	s := session.(*paypal.Session)
-	a.Equal(s.AuthURL, "https://www.paypal.com/webapps/auth/protocol/openidconnect/v1/authorize")
+	a.Equal(s.AuthURL, "https://www.paypal.com/signin/authorize")
 	a.Equal(s.AccessToken, "1234567890")
 }
 
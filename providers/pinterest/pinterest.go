@@ -0,0 +1,181 @@
+// Package pinterest implements the OAuth2 protocol for authenticating users through Pinterest.
+// This package can be used as a reference implementation of an OAuth2 provider for Goth.
+package pinterest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/markbates/goth"
+	"golang.org/x/oauth2"
+)
+
+const (
+	authURL         string = "https://www.pinterest.com/oauth/"
+	tokenURL        string = "https://api.pinterest.com/v5/oauth/token"
+	endpointProfile string = "https://api.pinterest.com/v5/user_account"
+)
+
+// Provider is the implementation of `goth.Provider` for accessing Pinterest.
+type Provider struct {
+	ClientKey    string
+	Secret       string
+	CallbackURL  string
+	HTTPClient   *http.Client
+	config       *oauth2.Config
+	providerName string
+	profileURL   string
+}
+
+// New creates a new Pinterest provider and sets up important connection details.
+// You should always call `pinterest.New` to get a new provider.  Never try to
+// create one manually.
+func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "pinterest",
+		profileURL:   endpointProfile,
+	}
+	p.config = newConfig(p, scopes)
+	return p
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+func (p *Provider) Client() *http.Client {
+	return goth.UserAgentClient(p.HTTPClient)
+}
+
+// Debug is a no-op for the pinterest package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth asks Pinterest for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return &Session{
+		AuthURL: p.config.AuthCodeURL(state),
+	}, nil
+}
+
+// FetchUser will go to Pinterest and access basic information about the user.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	s := session.(*Session)
+	user := goth.User{
+		AccessToken:  s.AccessToken,
+		Provider:     p.Name(),
+		RefreshToken: s.RefreshToken,
+		ExpiresAt:    s.ExpiresAt,
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	req, err := http.NewRequest("GET", p.profileURL, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.AccessToken)
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return user, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
+	}
+
+	err = userFromReader(resp.Body, &user)
+	return user, err
+}
+
+func newConfig(provider *Provider, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  authURL,
+			TokenURL: tokenURL,
+			// Pinterest requires the client credentials to be sent using
+			// HTTP Basic authentication when exchanging the code.
+			AuthStyle: oauth2.AuthStyleInHeader,
+		},
+		Scopes: []string{},
+	}
+
+	if len(scopes) > 0 {
+		c.Scopes = append(c.Scopes, scopes...)
+	} else {
+		c.Scopes = []string{"user_accounts:read"}
+	}
+
+	return c
+}
+
+func userFromReader(r io.Reader, user *goth.User) error {
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(r); err != nil {
+		return err
+	}
+
+	u := struct {
+		ID           string `json:"id"`
+		Username     string `json:"username"`
+		BusinessName string `json:"business_name"`
+		About        string `json:"about"`
+		ProfileImage string `json:"profile_image"`
+	}{}
+	if err := json.Unmarshal(buf.Bytes(), &u); err != nil {
+		return err
+	}
+
+	// account_type, website_url and the board/pin/follower counts are
+	// only available from RawData.
+	if err := json.Unmarshal(buf.Bytes(), &user.RawData); err != nil {
+		return err
+	}
+
+	user.UserID = u.ID
+	if user.UserID == "" {
+		user.UserID = u.Username
+	}
+	user.NickName = u.Username
+	user.Name = u.BusinessName
+	if user.Name == "" {
+		user.Name = u.Username
+	}
+	user.Description = u.About
+	user.AvatarURL = u.ProfileImage
+	return nil
+}
+
+// RefreshTokenAvailable refresh token is provided by auth provider or not
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
+}
+
+// RefreshToken get new access token based on the refresh token
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	token := &oauth2.Token{RefreshToken: refreshToken}
+	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
+	newToken, err := ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	return newToken, err
+}
@@ -0,0 +1,100 @@
+package pinterest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func provider() *Provider {
+	return New(os.Getenv("PINTEREST_KEY"), os.Getenv("PINTEREST_SECRET"), "/foo")
+}
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	a.Equal(p.ClientKey, os.Getenv("PINTEREST_KEY"))
+	a.Equal(p.Secret, os.Getenv("PINTEREST_SECRET"))
+	a.Equal(p.CallbackURL, "/foo")
+	a.Equal(p.config.Endpoint.AuthStyle, oauth2.AuthStyleInHeader)
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	session, err := p.BeginAuth("test_state")
+	s := session.(*Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "www.pinterest.com/oauth/")
+	a.Contains(s.AuthURL, fmt.Sprintf("client_id=%s", os.Getenv("PINTEREST_KEY")))
+	a.Contains(s.AuthURL, "state=test_state")
+	a.Contains(s.AuthURL, "scope=user_accounts%3Aread")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("Bearer 1234567890", r.Header.Get("Authorization"))
+		fmt.Fprint(w, testUserAccountResponse)
+	}))
+	defer ts.Close()
+	p.profileURL = ts.URL
+
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://www.pinterest.com/oauth/","AccessToken":"1234567890"}`)
+	a.NoError(err)
+	user, err := p.FetchUser(session)
+	a.NoError(err)
+	a.Equal("549755885175", user.UserID)
+	a.Equal("homer", user.NickName)
+	a.Equal("homer", user.Name)
+	a.Equal("https://i.pinimg.com/homer.jpg", user.AvatarURL)
+	a.Equal("BUSINESS", user.RawData["account_type"])
+	a.Equal(float64(12), user.RawData["board_count"])
+	a.Equal(float64(340), user.RawData["pin_count"])
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://www.pinterest.com/oauth/","AccessToken":"1234567890"}`)
+	a.NoError(err)
+
+	s := session.(*Session)
+	a.Equal(s.AuthURL, "https://www.pinterest.com/oauth/")
+	a.Equal(s.AccessToken, "1234567890")
+}
+
+var testUserAccountResponse = `{
+	"account_type": "BUSINESS",
+	"id": "549755885175",
+	"profile_image": "https://i.pinimg.com/homer.jpg",
+	"website_url": "https://example.com",
+	"username": "homer",
+	"about": "",
+	"business_name": "",
+	"board_count": 12,
+	"pin_count": 340,
+	"follower_count": 7,
+	"following_count": 3,
+	"monthly_views": 100
+}`
@@ -62,6 +62,11 @@ func (p *Provider) UnmarshalSession(s string) (goth.Session, error) {
 	return session, nil
 }
 
+// Client returns the HTTP client used to call Reddit.
+func (p *Provider) Client() *http.Client {
+	return goth.UserAgentClient(&p.client)
+}
+
 func (p *Provider) Debug(b bool) {}
 
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
@@ -92,7 +97,7 @@ func (p *Provider) FetchUser(s goth.Session) (goth.User, error) {
 	bearer := "Bearer " + session.AccessToken
 	request.Header.Add("Authorization", bearer)
 
-	res, err := p.client.Do(request)
+	res, err := p.Client().Do(request)
 	if err != nil {
 		return goth.User{}, err
 	}
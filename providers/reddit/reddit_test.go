@@ -43,6 +43,10 @@ func TestProvider(t *testing.T) {
 
 	t.Run("fetch reddit user that created the given session", func(t *testing.T) {
 		redditServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			// Reddit rejects Go's default User-Agent
+			if ua := request.Header.Get("User-Agent"); ua != goth.DefaultUserAgent {
+				t.Errorf("unexpected User-Agent %q", ua)
+			}
 			b, err := json.Marshal(response)
 			if err != nil {
 				t.Fatal(err)
@@ -26,6 +26,7 @@ type Provider struct {
 	ClientKey    string
 	Secret       string
 	CallbackURL  string
+	HTTPClient   *http.Client
 	config       *oauth2.Config
 	providerName string
 }
@@ -54,6 +55,11 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// Client returns the HTTP client used to call SeaTalk.
+func (p *Provider) Client() *http.Client {
+	return goth.UserAgentClient(p.HTTPClient)
+}
+
 // BeginAuth asks SeaTalk for an authentication endpoint.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 	url := p.config.AuthCodeURL(state)
@@ -91,7 +97,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	response, err := http.Get(endpointProfile + "?access_token=" + url.QueryEscape(sess.AccessToken))
+	response, err := p.Client().Get(endpointProfile + "?access_token=" + url.QueryEscape(sess.AccessToken))
 	if err != nil {
 		return user, err
 	}
@@ -1,7 +1,6 @@
 package seatalk
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"time"
@@ -28,7 +27,7 @@ func (s *Session) GetAuthURL() (string, error) {
 // Authorize the session with SeaTalk and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
-	token, err := p.config.Exchange(context.Background(), params.Get("code"))
+	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"))
 	if err != nil {
 		return "", err
 	}
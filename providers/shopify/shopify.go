@@ -52,7 +52,7 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 
 // Client is HTTP client to be used in all fetch operations.
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Name is the name used to retrieve this provider later.
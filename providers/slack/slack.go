@@ -63,7 +63,7 @@ func (p *Provider) SetName(name string) {
 
 // Client returns the http.Client used in the provider.
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the slack package.
@@ -58,7 +58,7 @@ func (p *Provider) SetName(name string) {
 
 // Client returns an HTTP client to be used in all fetch operations.
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the strava package.
@@ -11,11 +11,12 @@ import (
 
 // Session stores data during the auth process with Stripe.
 type Session struct {
-	AuthURL      string
-	AccessToken  string
-	RefreshToken string
-	ExpiresAt    time.Time
-	ID           string
+	AuthURL        string
+	AccessToken    string
+	RefreshToken   string
+	ExpiresAt      time.Time
+	ID             string // stripe_user_id of the connected account
+	PublishableKey string
 }
 
 var _ goth.Session = &Session{}
@@ -43,7 +44,10 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	s.AccessToken = token.AccessToken
 	s.RefreshToken = token.RefreshToken
 	s.ExpiresAt = token.Expiry
-	s.ID = token.Extra("stripe_user_id").(string) // Required to get the user info from sales force
+	// Stripe returns the connected account with the token; the id is
+	// required to get the account info
+	s.ID, _ = token.Extra("stripe_user_id").(string)
+	s.PublishableKey, _ = token.Extra("stripe_publishable_key").(string)
 	return token.AccessToken, err
 }
 
@@ -12,6 +12,14 @@ import (
 	"golang.org/x/oauth2"
 )
 
+// These scopes may be passed to New. Stripe Connect only accepts one of them.
+const (
+	// ScopeReadOnly grants read access to the connected account. This is the default.
+	ScopeReadOnly string = "read_only"
+	// ScopeReadWrite grants read and write access to the connected account.
+	ScopeReadWrite string = "read_write"
+)
+
 const (
 	authURL         string = "https://connect.stripe.com/oauth/authorize"
 	tokenURL        string = "https://connect.stripe.com/oauth/token"
@@ -53,7 +61,7 @@ func (p *Provider) SetName(name string) {
 }
 
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the stripe package.
@@ -74,6 +82,13 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		Provider:     p.Name(),
 		RefreshToken: s.RefreshToken,
 		ExpiresAt:    s.ExpiresAt,
+		// the connected account id is returned with the token, there is
+		// no userinfo endpoint as such
+		UserID: s.ID,
+		RawData: map[string]interface{}{
+			"stripe_user_id":         s.ID,
+			"stripe_publishable_key": s.PublishableKey,
+		},
 	}
 
 	if user.AccessToken == "" {
@@ -141,7 +156,9 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	user.Email = u.Email // email is not provided by yahoo
 	user.Name = u.Name
 	user.NickName = u.Name
-	user.UserID = u.ID
+	if u.ID != "" {
+		user.UserID = u.ID
+	}
 	user.Location = u.Address.Location
 	user.AvatarURL = u.AvatarURL
 	return nil
@@ -1,11 +1,15 @@
 package stripe_test
 
 import (
+	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"testing"
 
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/stripe"
+	"github.com/markbates/goth/testsupport"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -35,6 +39,47 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "connect.stripe.com/oauth/authorize")
 }
 
+func Test_BeginAuthWithScope(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := stripe.New(os.Getenv("STRIPE_KEY"), os.Getenv("STRIPE_SECRET"), "/foo", stripe.ScopeReadWrite)
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*stripe.Session).AuthURL, "scope=read_write")
+}
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	p.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Host {
+		case "connect.stripe.com":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"sk_test_123","token_type":"bearer","scope":"read_write","livemode":false,"refresh_token":"rt_123","stripe_user_id":"acct_123","stripe_publishable_key":"pk_test_123"}`)
+		default:
+			a.Equal("/v1/accounts/acct_123", r.URL.Path)
+			fmt.Fprint(w, `{"id":"acct_123","email":"seller@example.com","display_name":"Seller"}`)
+		}
+	})
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	_, err = session.Authorize(p, url.Values{"code": {"ac_123"}})
+	a.NoError(err)
+
+	s := session.(*stripe.Session)
+	a.Equal("acct_123", s.ID)
+	a.Equal("pk_test_123", s.PublishableKey)
+
+	user, err := p.FetchUser(session)
+	a.NoError(err)
+	a.Equal("acct_123", user.UserID)
+	a.Equal("seller@example.com", user.Email)
+	a.Equal("acct_123", user.RawData["stripe_user_id"])
+	a.Equal("pk_test_123", user.RawData["stripe_publishable_key"])
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -62,7 +62,7 @@ func (p *Provider) SetName(name string) {
 }
 
 func (p *Provider) GetClient() *http.Client {
-	return goth.HTTPClientWithFallBack(p.Client)
+	return goth.UserAgentClient(p.Client)
 }
 
 // Debug TODO
@@ -250,7 +250,7 @@ func (p *Provider) SetName(name string) {
 
 // Client ...
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is no-op for the Twitch package.
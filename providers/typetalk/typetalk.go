@@ -61,7 +61,7 @@ func (p *Provider) SetName(name string) {
 
 // Client returns HTTP client.
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the typetalk package.
@@ -32,7 +32,7 @@ func (s Session) GetAuthURL() (string, error) {
 // Authorize the session with Wepay and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
-	token, openid, err := p.fetchToken(params.Get("code"))
+	token, err := p.fetchToken(params.Get("code"))
 
 	if err != nil {
 		return "", err
@@ -45,7 +45,9 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	s.AccessToken = token.AccessToken
 	s.RefreshToken = token.RefreshToken
 	s.ExpiresAt = token.Expiry
-	s.Openid = openid
+	// the openid is required to fetch the user
+	s.Openid, _ = token.Extra("openid").(string)
+	s.Unionid, _ = token.Extra("unionid").(string)
 	return token.AccessToken, err
 }
 
@@ -12,11 +12,22 @@ import (
 	"golang.org/x/oauth2"
 )
 
+// WeChat does not follow the OAuth2 spec closely: the client credentials are
+// sent as "appid" and "secret", the token response carries the "openid"
+// needed to fetch the user, and websites (open platform) and official
+// accounts use different authorization endpoints.
 const (
-	AuthURL  = "https://open.weixin.qq.com/connect/qrconnect"
-	TokenURL = "https://api.weixin.qq.com/sns/oauth2/access_token"
-
-	ScopeSnsapiLogin = "snsapi_login"
+	// AuthURL is the QR code login page of the open platform, used by websites.
+	AuthURL = "https://open.weixin.qq.com/connect/qrconnect"
+	// OfficialAccountAuthURL is used by web pages opened inside WeChat on
+	// behalf of an official account.
+	OfficialAccountAuthURL = "https://open.weixin.qq.com/connect/oauth2/authorize"
+	TokenURL               = "https://api.weixin.qq.com/sns/oauth2/access_token"
+	RefreshTokenURL        = "https://api.weixin.qq.com/sns/oauth2/refresh_token"
+
+	ScopeSnsapiLogin    = "snsapi_login"
+	ScopeSnsapiUserinfo = "snsapi_userinfo"
+	ScopeSnsapiBase     = "snsapi_base"
 
 	ProfileURL = "https://api.weixin.qq.com/sns/userinfo"
 )
@@ -24,15 +35,18 @@ const (
 type Provider struct {
 	providerName string
 	config       *oauth2.Config
-	httpClient   *http.Client
+	HTTPClient   *http.Client
 	ClientID     string
 	ClientSecret string
 	RedirectURL  string
 	Lang         WechatLangType
 
-	AuthURL    string
-	TokenURL   string
-	ProfileURL string
+	AuthURL         string
+	TokenURL        string
+	RefreshTokenURL string
+	ProfileURL      string
+
+	scope string
 }
 
 type WechatLangType string
@@ -55,11 +69,26 @@ func New(clientID, clientSecret, redirectURL string, lang WechatLangType) *Provi
 		AuthURL:      AuthURL,
 		TokenURL:     TokenURL,
 		ProfileURL:   ProfileURL,
+
+		RefreshTokenURL: RefreshTokenURL,
+		scope:           ScopeSnsapiLogin,
 	}
 	p.config = newConfig(p)
 	return p
 }
 
+// NewOfficialAccount creates a new Wechat provider for web pages opened inside
+// WeChat on behalf of an official account. scope is either ScopeSnsapiUserinfo,
+// which asks for the user's consent and allows fetching the profile, or
+// ScopeSnsapiBase, which is silent but only yields the openid.
+func NewOfficialAccount(clientID, clientSecret, redirectURL string, lang WechatLangType, scope string) *Provider {
+	p := New(clientID, clientSecret, redirectURL, lang)
+	p.AuthURL = OfficialAccountAuthURL
+	p.scope = scope
+	p.config = newConfig(p)
+	return p
+}
+
 // Name is the name used to retrieve this provider later.
 func (p *Provider) Name() string {
 	return p.providerName
@@ -71,7 +100,7 @@ func (p *Provider) SetName(name string) {
 }
 
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.httpClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug is a no-op for the wechat package.
@@ -83,10 +112,15 @@ func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 	params.Add("appid", p.ClientID)
 	params.Add("response_type", "code")
 	params.Add("state", state)
-	params.Add("scope", ScopeSnsapiLogin)
+	params.Add("scope", p.scope)
 	params.Add("redirect_uri", p.RedirectURL)
+	authURL := fmt.Sprintf("%s?%s", p.AuthURL, params.Encode())
+	if p.AuthURL == OfficialAccountAuthURL {
+		// required by WeChat, the page won't redirect without it
+		authURL += "#wechat_redirect"
+	}
 	session := &Session{
-		AuthURL: fmt.Sprintf("%s?%s", p.AuthURL, params.Encode()),
+		AuthURL: authURL,
 	}
 	return session, nil
 }
@@ -131,6 +165,10 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	}
 
 	err = userFromReader(resp.Body, &user)
+	if err == nil && user.RawData["Unionid"] == "" {
+		// the unionid is also returned by the token exchange
+		user.RawData["Unionid"] = s.Unionid
+	}
 	return user, err
 }
 
@@ -146,7 +184,7 @@ func newConfig(provider *Provider) *oauth2.Config {
 		Scopes: []string{},
 	}
 
-	c.Scopes = append(c.Scopes, ScopeSnsapiLogin)
+	c.Scopes = append(c.Scopes, provider.scope)
 
 	return c
 }
@@ -187,51 +225,66 @@ func userFromReader(r io.Reader, user *goth.User) error {
 
 // RefreshTokenAvailable refresh token is provided by auth provider or not
 func (p *Provider) RefreshTokenAvailable() bool {
-	return false
+	return true
 }
 
-// RefreshToken get new access token based on the refresh token
+// RefreshToken get new access token based on the refresh token.
+// The openid and unionid of the user are available with Token.Extra.
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-
-	return nil, nil
+	params := url.Values{}
+	params.Add("appid", p.ClientID)
+	params.Add("grant_type", "refresh_token")
+	params.Add("refresh_token", refreshToken)
+	return p.requestToken(p.RefreshTokenURL, params)
 }
 
-func (p *Provider) fetchToken(code string) (*oauth2.Token, string, error) {
-
+func (p *Provider) fetchToken(code string) (*oauth2.Token, error) {
 	params := url.Values{}
 	params.Add("appid", p.ClientID)
 	params.Add("secret", p.ClientSecret)
 	params.Add("grant_type", "authorization_code")
 	params.Add("code", code)
-	url := fmt.Sprintf("%s?%s", p.TokenURL, params.Encode())
+	return p.requestToken(p.TokenURL, params)
+}
+
+func (p *Provider) requestToken(endpoint string, params url.Values) (*oauth2.Token, error) {
+	url := fmt.Sprintf("%s?%s", endpoint, params.Encode())
 	resp, err := p.Client().Get(url)
 
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("wechat /gettoken returns code: %d", resp.StatusCode)
+		return nil, fmt.Errorf("wechat %s returns code: %d", endpoint, resp.StatusCode)
 	}
 
 	obj := struct {
-		AccessToken string        `json:"access_token"`
-		ExpiresIn   time.Duration `json:"expires_in"`
-		Openid      string        `json:"openid"`
-		Code        int           `json:"errcode"`
-		Msg         string        `json:"errmsg"`
+		AccessToken  string        `json:"access_token"`
+		ExpiresIn    time.Duration `json:"expires_in"`
+		RefreshToken string        `json:"refresh_token"`
+		Openid       string        `json:"openid"`
+		Unionid      string        `json:"unionid"`
+		Scope        string        `json:"scope"`
+		Code         int           `json:"errcode"`
+		Msg          string        `json:"errmsg"`
 	}{}
 	if err = json.NewDecoder(resp.Body).Decode(&obj); err != nil {
-		return nil, "", err
+		return nil, err
 	}
 	if obj.Code != 0 {
-		return nil, "", fmt.Errorf("CODE: %d, MSG: %s", obj.Code, obj.Msg)
+		return nil, fmt.Errorf("CODE: %d, MSG: %s", obj.Code, obj.Msg)
 	}
 
 	token := &oauth2.Token{
-		AccessToken: obj.AccessToken,
-		Expiry:      time.Now().Add(obj.ExpiresIn * time.Second),
+		AccessToken:  obj.AccessToken,
+		RefreshToken: obj.RefreshToken,
+		Expiry:       time.Now().Add(obj.ExpiresIn * time.Second),
 	}
 
-	return token, obj.Openid, nil
+	return token.WithExtra(map[string]interface{}{
+		"openid":  obj.Openid,
+		"unionid": obj.Unionid,
+		"scope":   obj.Scope,
+	}), nil
 }
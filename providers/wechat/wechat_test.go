@@ -1,6 +1,10 @@
 package wechat_test
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
 
@@ -35,6 +39,96 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "open.weixin.qq.com/connect/qrconnect")
 }
 
+func Test_BeginAuth_OfficialAccount(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := wechat.NewOfficialAccount(os.Getenv("WECHAT_KEY"), os.Getenv("WECHAT_SECRET"), "/foo", wechat.WECHAT_LANG_CN, wechat.ScopeSnsapiUserinfo)
+	session, err := p.BeginAuth("test_state")
+	s := session.(*wechat.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "open.weixin.qq.com/connect/oauth2/authorize")
+	a.Contains(s.AuthURL, "scope=snsapi_userinfo")
+	a.Contains(s.AuthURL, "#wechat_redirect")
+}
+
+func Test_AuthorizeAndFetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			a.Equal("appid", r.URL.Query().Get("appid"))
+			a.Equal("secret", r.URL.Query().Get("secret"))
+			a.Equal("code", r.URL.Query().Get("code"))
+			fmt.Fprint(w, `{"access_token":"access","expires_in":7200,"refresh_token":"refresh","openid":"OPENID","unionid":"UNIONID","scope":"snsapi_login"}`)
+		case "/userinfo":
+			a.Equal("access", r.URL.Query().Get("access_token"))
+			a.Equal("OPENID", r.URL.Query().Get("openid"))
+			fmt.Fprint(w, `{"openid":"OPENID","nickname":"Band","headimgurl":"http://example.com/avatar.png"}`)
+		}
+	}))
+	defer ts.Close()
+
+	p := wechat.New("appid", "secret", "/foo", wechat.WECHAT_LANG_CN)
+	p.TokenURL = ts.URL + "/token"
+	p.ProfileURL = ts.URL + "/userinfo"
+
+	s := &wechat.Session{}
+	_, err := s.Authorize(p, url.Values{"code": {"code"}})
+	a.NoError(err)
+	a.Equal("access", s.AccessToken)
+	a.Equal("refresh", s.RefreshToken)
+	a.Equal("OPENID", s.Openid)
+	a.Equal("UNIONID", s.Unionid)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("OPENID", user.UserID)
+	a.Equal("Band", user.NickName)
+	a.Equal("http://example.com/avatar.png", user.AvatarURL)
+	a.Equal("UNIONID", user.RawData["Unionid"])
+}
+
+func Test_RefreshToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("appid", r.URL.Query().Get("appid"))
+		a.Equal("refresh_token", r.URL.Query().Get("grant_type"))
+		a.Equal("refresh", r.URL.Query().Get("refresh_token"))
+		fmt.Fprint(w, `{"access_token":"new_access","expires_in":7200,"refresh_token":"refresh","openid":"OPENID"}`)
+	}))
+	defer ts.Close()
+
+	p := wechat.New("appid", "secret", "/foo", wechat.WECHAT_LANG_CN)
+	p.RefreshTokenURL = ts.URL
+	a.True(p.RefreshTokenAvailable())
+
+	token, err := p.RefreshToken("refresh")
+	a.NoError(err)
+	a.Equal("new_access", token.AccessToken)
+	a.Equal("OPENID", token.Extra("openid"))
+}
+
+func Test_RefreshToken_Error(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"errcode":40030,"errmsg":"invalid refresh_token"}`)
+	}))
+	defer ts.Close()
+
+	p := wechat.New("appid", "secret", "/foo", wechat.WECHAT_LANG_CN)
+	p.RefreshTokenURL = ts.URL
+
+	_, err := p.RefreshToken("refresh")
+	a.Error(err)
+	a.Contains(err.Error(), "40030")
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -105,7 +105,7 @@ func (p *Provider) SetName(name string) {
 
 // Client does pretty much everything
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Debug sets the logging of the OAuth client to verbose.
@@ -15,9 +15,9 @@ import (
 )
 
 const (
-	authEndpoint    string = "https://oauth.yandex.ru/authorize"
+	authEndpoint    string = "https://oauth.yandex.com/authorize"
 	tokenEndpoint   string = "https://oauth.yandex.com/token"
-	profileEndpoint string = "https://login.yandex.ru/info"
+	profileEndpoint string = "https://login.yandex.ru/info?format=json"
 	avatarURL       string = "https://avatars.yandex.net/get-yapic"
 	avatarSize      string = "islands-200"
 )
@@ -47,7 +47,7 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 }
 
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return goth.UserAgentClient(p.HTTPClient)
 }
 
 // Name is the name used to retrieve this provider later.
@@ -89,6 +89,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	if err != nil {
 		return user, err
 	}
+	// Yandex expects its own "OAuth" scheme rather than "Bearer"
 	req.Header.Set("Authorization", "OAuth "+sess.AccessToken)
 	resp, err := p.Client().Do(req)
 	if err != nil {
@@ -143,6 +144,7 @@ func userFromReader(r io.Reader, user *goth.User) error {
 		UserID        string `json:"id"`
 		Email         string `json:"default_email"`
 		Login         string `json:"login"`
+		DisplayName   string `json:"display_name"`
 		Name          string `json:"real_name"`
 		FirstName     string `json:"first_name"`
 		LastName      string `json:"last_name"`
@@ -157,7 +159,10 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	user.UserID = u.UserID
 	user.Email = u.Email
 	user.NickName = u.Login
-	user.Name = u.Name
+	user.Name = u.DisplayName
+	if user.Name == "" {
+		user.Name = u.Name
+	}
 	user.FirstName = u.FirstName
 	user.LastName = u.LastName
 	if u.AvatarID != `` {
@@ -1,11 +1,14 @@
 package yandex_test
 
 import (
+	"fmt"
+	"net/http"
 	"os"
 	"testing"
 
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/yandex"
+	"github.com/markbates/goth/testsupport"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -40,7 +43,7 @@ func Test_BeginAuth(t *testing.T) {
 	session, err := p.BeginAuth("test_state")
 	s := session.(*yandex.Session)
 	a.NoError(err)
-	a.Contains(s.AuthURL, "https://oauth.yandex.ru/authorize")
+	a.Contains(s.AuthURL, "https://oauth.yandex.com/authorize")
 }
 
 func Test_SessionFromJSON(t *testing.T) {
@@ -48,14 +51,35 @@ func Test_SessionFromJSON(t *testing.T) {
 	a := assert.New(t)
 
 	p := provider()
-	session, err := p.UnmarshalSession(`{"AuthURL":"https://oauth.yandex.ru/authorize","AccessToken":"1234567890"}`)
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://oauth.yandex.com/authorize","AccessToken":"1234567890"}`)
 	a.NoError(err)
 
 	s := session.(*yandex.Session)
-	a.Equal(s.AuthURL, "https://oauth.yandex.ru/authorize")
+	a.Equal(s.AuthURL, "https://oauth.yandex.com/authorize")
 	a.Equal(s.AccessToken, "1234567890")
 }
 
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	p.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("login.yandex.ru", r.URL.Host)
+		a.Equal("json", r.URL.Query().Get("format"))
+		a.Equal("OAuth 1234567890", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"id":"1000034426","login":"ivan","display_name":"Ivan","real_name":"Ivan Ivanov","default_email":"ivan@yandex.ru","default_avatar_id":"131652443"}`)
+	})
+
+	user, err := p.FetchUser(&yandex.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("1000034426", user.UserID)
+	a.Equal("Ivan", user.Name)
+	a.Equal("ivan", user.NickName)
+	a.Equal("ivan@yandex.ru", user.Email)
+	a.Equal("https://avatars.yandex.net/get-yapic/131652443/islands-200", user.AvatarURL)
+}
+
 func provider() *yandex.Provider {
 	return yandex.New(os.Getenv("YANDEX_KEY"), os.Getenv("YANDEX_SECRET"), "/foo")
 }
@@ -1,5 +1,14 @@
 package goth
 
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
 // Params is used to pass data to sessions for authorization. An existing
 // implementation, and the one most likely to be used, is `url.Values`.
 type Params interface {
@@ -19,3 +28,147 @@ type Session interface {
 	// that can be stored for later access to the provider.
 	Authorize(Provider, Params) (string, error)
 }
+
+// ContextAuthorizer is implemented by sessions whose Authorize stops once
+// ctx is done.
+type ContextAuthorizer interface {
+	AuthorizeContext(ctx context.Context, provider Provider, params Params) (string, error)
+}
+
+// AuthorizeContext authorizes sess with provider, using its AuthorizeContext
+// method when it implements ContextAuthorizer and falling back to Authorize
+// otherwise, in which case ctx is ignored.
+func AuthorizeContext(ctx context.Context, sess Session, provider Provider, params Params) (string, error) {
+	if ca, ok := sess.(ContextAuthorizer); ok {
+		return ca.AuthorizeContext(ctx, provider, params)
+	}
+	return sess.Authorize(provider, params)
+}
+
+// ExpiringSession is implemented by sessions that know when their access
+// token expires.
+type ExpiringSession interface {
+	// TokenExpiry returns the expiry of the access token, or the zero time
+	// when it is unknown.
+	TokenExpiry() time.Time
+}
+
+// SessionExpiry returns the expiry of the access token held by sess, without
+// any network call. It returns the zero time when sess does not implement
+// ExpiringSession or the expiry is unknown.
+func SessionExpiry(sess Session) time.Time {
+	if es, ok := sess.(ExpiringSession); ok {
+		return es.TokenExpiry()
+	}
+	return time.Time{}
+}
+
+// SessionTokens are the tokens held by a session, see TokenSession. Tokens a
+// session does not have are left empty.
+type SessionTokens struct {
+	AccessToken       string
+	AccessTokenSecret string
+	RefreshToken      string
+	IDToken           string
+	ExpiresAt         time.Time
+}
+
+// TokenSession is implemented by sessions that can hand out their tokens
+// without fetching the user, e.g. for gothic.CompleteAuthTokensOnly.
+type TokenSession interface {
+	SessionTokens() SessionTokens
+}
+
+// UnmarshalSession rebuilds a session with the UnmarshalSession method of the
+// named provider, which must have been registered with UseProviders.
+func UnmarshalSession(providerName, data string) (Session, error) {
+	provider, err := GetProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	return provider.UnmarshalSession(data)
+}
+
+// TypedSession wraps a session along with the name of its provider, so that
+// sessions of different providers can be stored side by side and restored
+// with UnmarshalTypedSession without knowing their provider beforehand.
+type TypedSession struct {
+	Provider string
+	Session  Session
+}
+
+type typedSessionJSON struct {
+	Provider string `json:"provider"`
+	Session  string `json:"session"`
+}
+
+// Marshal returns the session marshaled by its provider, embedded in a JSON
+// object along with the provider name.
+func (s TypedSession) Marshal() string {
+	b, _ := json.Marshal(typedSessionJSON{Provider: s.Provider, Session: s.Session.Marshal()})
+	return string(b)
+}
+
+// UnmarshalTypedSession restores a session marshaled by TypedSession.Marshal,
+// using the provider it names.
+func UnmarshalTypedSession(data string) (TypedSession, error) {
+	var raw typedSessionJSON
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		return TypedSession{}, err
+	}
+	if raw.Provider == "" {
+		return TypedSession{}, errors.New("typed session has no provider name")
+	}
+	sess, err := UnmarshalSession(raw.Provider, raw.Session)
+	if err != nil {
+		return TypedSession{}, err
+	}
+	return TypedSession{Provider: raw.Provider, Session: sess}, nil
+}
+
+// authStateVersion is the first byte of the blobs made by EncodeAuthState.
+const authStateVersion byte = 1
+
+// EncodeAuthState returns an opaque blob holding the session along with the
+// name of its provider, to be stored by applications keeping the auth state
+// in their own database. Everything the provider keeps in its session, such
+// as PKCE verifiers or nonces, is included. Restore it with DecodeAuthState.
+func EncodeAuthState(providerName string, sess Session) (string, error) {
+	if providerName == "" {
+		return "", errors.New("auth state needs a provider name")
+	}
+	if sess == nil {
+		return "", errors.New("auth state needs a session")
+	}
+	b, err := json.Marshal(typedSessionJSON{Provider: providerName, Session: sess.Marshal()})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(append([]byte{authStateVersion}, b...)), nil
+}
+
+// DecodeAuthState restores a blob made by EncodeAuthState, unmarshaling the
+// session with the named provider, which must have been registered with
+// UseProviders.
+func DecodeAuthState(data string) (providerName string, sess Session, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid auth state: %w", err)
+	}
+	if len(b) == 0 {
+		return "", nil, errors.New("invalid auth state: empty")
+	}
+	if b[0] != authStateVersion {
+		return "", nil, fmt.Errorf("unsupported auth state version %d", b[0])
+	}
+
+	var raw typedSessionJSON
+	if err := json.Unmarshal(b[1:], &raw); err != nil {
+		return "", nil, fmt.Errorf("invalid auth state: %w", err)
+	}
+	sess, err = UnmarshalSession(raw.Provider, raw.Session)
+	if err != nil {
+		return "", nil, err
+	}
+	return raw.Provider, sess, nil
+}
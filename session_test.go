@@ -0,0 +1,121 @@
+package goth_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/faux"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TypedSession(t *testing.T) {
+	a := assert.New(t)
+
+	provider := &faux.Provider{}
+	goth.UseProviders(provider)
+	defer goth.ClearProviders()
+
+	sess, err := provider.BeginAuth("state")
+	a.NoError(err)
+
+	data := goth.TypedSession{Provider: provider.Name(), Session: sess}.Marshal()
+	typed, err := goth.UnmarshalTypedSession(data)
+	a.NoError(err)
+	a.Equal(provider.Name(), typed.Provider)
+	a.Equal(sess.Marshal(), typed.Session.Marshal())
+
+	_, err = goth.UnmarshalTypedSession(`{"provider":"unknown","session":"{}"}`)
+	a.EqualError(err, "no provider for unknown exists")
+
+	_, err = goth.UnmarshalTypedSession(`{"session":"{}"}`)
+	a.Error(err)
+}
+
+func Test_UnmarshalSession(t *testing.T) {
+	a := assert.New(t)
+
+	provider := &faux.Provider{}
+	goth.UseProviders(provider)
+	defer goth.ClearProviders()
+
+	sess, err := goth.UnmarshalSession(provider.Name(), `{"ID":"id","AuthURL":"http://example.com/auth"}`)
+	a.NoError(err)
+	url, err := sess.GetAuthURL()
+	a.NoError(err)
+	a.Equal("http://example.com/auth", url)
+}
+
+func Test_AuthState(t *testing.T) {
+	a := assert.New(t)
+
+	provider := &faux.Provider{}
+	goth.UseProviders(provider)
+	defer goth.ClearProviders()
+
+	sess, err := provider.BeginAuth("state")
+	a.NoError(err)
+
+	data, err := goth.EncodeAuthState(provider.Name(), sess)
+	a.NoError(err)
+	name, restored, err := goth.DecodeAuthState(data)
+	a.NoError(err)
+	a.Equal(provider.Name(), name)
+	a.Equal(sess.Marshal(), restored.Marshal())
+
+	_, err = goth.EncodeAuthState("", sess)
+	a.Error(err)
+
+	_, _, err = goth.DecodeAuthState("AnsifQ")
+	a.EqualError(err, "unsupported auth state version 2")
+	_, _, err = goth.DecodeAuthState("not base64!")
+	a.Error(err)
+}
+
+type expiringSession struct {
+	faux.Session
+	expiresAt time.Time
+}
+
+func (s expiringSession) TokenExpiry() time.Time {
+	return s.expiresAt
+}
+
+func Test_SessionExpiry(t *testing.T) {
+	a := assert.New(t)
+
+	a.True(goth.SessionExpiry(&faux.Session{}).IsZero())
+
+	expiresAt := time.Now().Add(time.Hour)
+	a.Equal(expiresAt, goth.SessionExpiry(&expiringSession{expiresAt: expiresAt}))
+}
+
+type contextSession struct {
+	faux.Session
+	ctx context.Context
+}
+
+func (s *contextSession) AuthorizeContext(ctx context.Context, provider goth.Provider, params goth.Params) (string, error) {
+	s.ctx = ctx
+	return s.Authorize(provider, params)
+}
+
+func Test_AuthorizeContext(t *testing.T) {
+	a := assert.New(t)
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	sess := &contextSession{}
+	token, err := goth.AuthorizeContext(ctx, sess, &faux.Provider{}, url.Values{})
+	a.NoError(err)
+	a.Equal("access", token)
+	a.Equal(ctx, sess.ctx)
+
+	// faux has no context variant
+	token, err = goth.AuthorizeContext(ctx, &faux.Session{}, &faux.Provider{}, url.Values{})
+	a.NoError(err)
+	a.Equal("access", token)
+}
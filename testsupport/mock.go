@@ -0,0 +1,32 @@
+package testsupport
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/jarcoal/httpmock"
+)
+
+// MockClient returns an HTTP client answering every request with handler
+// instead of going out to the network, whatever the host of the request, so
+// that a provider can be tested without changing its endpoints:
+//
+//	provider.HTTPClient = testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+//		fmt.Fprint(w, `{"id":"1234"}`)
+//	})
+func MockClient(handler http.HandlerFunc) *http.Client {
+	return MockClientFunc(func(req *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Result(), nil
+	})
+}
+
+// MockClientFunc returns an HTTP client answering every request with
+// responder, which can also fail the request, e.g. with the error of its
+// context.
+func MockClientFunc(responder httpmock.Responder) *http.Client {
+	transport := httpmock.NewMockTransport()
+	transport.RegisterNoResponder(responder)
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,37 @@
+package testsupport_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/markbates/goth/testsupport"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MockClient(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	client := testsupport.MockClient(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s%s", r.URL.Host, r.URL.Path)
+	})
+	resp, err := client.Get("https://api.example.com/me")
+	a.NoError(err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	a.NoError(err)
+	a.Equal("api.example.com/me", string(body))
+
+	client = testsupport.MockClientFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, req.Context().Err()
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.example.com/me", nil)
+	a.NoError(err)
+	_, err = client.Do(req)
+	a.ErrorIs(err, context.Canceled)
+}
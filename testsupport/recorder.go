@@ -0,0 +1,297 @@
+// Package testsupport helps testing providers against real API responses.
+//
+// A Recorder sits in place of the transport of a provider's HTTP client. In
+// ModeRecord it forwards requests to the real API and keeps the interactions,
+// which Save writes as a JSON fixture once secrets have been removed. In
+// ModeReplay it answers requests from such a fixture, so tests run without
+// network access or live credentials:
+//
+//	rec, err := testsupport.New("testdata/fetch_user.json", testsupport.ModeReplay)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	provider.HTTPClient = rec.Client()
+package testsupport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// Mode tells a Recorder whether to record or replay interactions.
+type Mode int
+
+const (
+	// ModeReplay answers requests from the fixture file.
+	ModeReplay Mode = iota
+	// ModeRecord forwards requests to the real API and records them.
+	ModeRecord
+)
+
+// Redacted replaces secret values in recorded interactions.
+const Redacted = "REDACTED"
+
+// SecretHeaders are the headers whose values are redacted by Sanitize.
+var SecretHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// SecretParams are the query, form and JSON parameters whose values are
+// redacted by Sanitize.
+var SecretParams = []string{"access_token", "refresh_token", "id_token", "client_secret", "code", "code_verifier", "client_assertion"}
+
+// Request is a recorded HTTP request.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Response is a recorded HTTP response.
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Interaction is a request along with the response it received.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Recorder is an http.RoundTripper recording or replaying interactions.
+type Recorder struct {
+	// Transport is used to reach the real API in ModeRecord. It defaults to
+	// http.DefaultTransport.
+	Transport http.RoundTripper
+	// Sanitize removes secrets from an interaction before it is stored, and
+	// from requests before they are matched in ModeReplay. It defaults to
+	// the package-level Sanitize function.
+	Sanitize func(*Interaction)
+
+	path string
+	mode Mode
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// New returns a Recorder backed by the fixture file at path. In ModeReplay
+// the file is loaded immediately and must exist.
+func New(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{path: path, mode: mode}
+	if mode == ModeRecord {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &r.interactions); err != nil {
+		return nil, fmt.Errorf("testsupport: could not load %s: %w", path, err)
+	}
+	r.used = make([]bool, len(r.interactions))
+	return r, nil
+}
+
+// Client returns an HTTP client using the recorder as its transport.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Interactions returns the interactions recorded or loaded so far.
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.interactions...)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	in := Interaction{Request: Request{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: req.Header.Clone(),
+		Body:   reqBody,
+	}}
+
+	if r.mode == ModeRecord {
+		return r.record(req, in)
+	}
+	return r.replay(req, in)
+}
+
+func (r *Recorder) record(req *http.Request, in Interaction) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := readBody(&res.Body)
+	if err != nil {
+		return nil, err
+	}
+	in.Response = Response{
+		StatusCode: res.StatusCode,
+		Header:     res.Header.Clone(),
+		Body:       body,
+	}
+	r.sanitize(&in)
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, in)
+	r.mu.Unlock()
+	return res, nil
+}
+
+// replay answers with the first unused interaction having the same method and
+// URL, so that repeated requests get their responses in recorded order.
+func (r *Recorder) replay(req *http.Request, in Interaction) (*http.Response, error) {
+	r.sanitize(&in)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, recorded := range r.interactions {
+		if r.used[i] || recorded.Request.Method != in.Request.Method || recorded.Request.URL != in.Request.URL {
+			continue
+		}
+		r.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.Response.StatusCode, http.StatusText(recorded.Response.StatusCode)),
+			StatusCode:    recorded.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        recorded.Response.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewBufferString(recorded.Response.Body)),
+			ContentLength: int64(len(recorded.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("testsupport: no recorded interaction for %s %s", in.Request.Method, in.Request.URL)
+}
+
+func (r *Recorder) sanitize(in *Interaction) {
+	if r.Sanitize != nil {
+		r.Sanitize(in)
+		return
+	}
+	Sanitize(in)
+}
+
+// Save writes the recorded interactions to the fixture file. It does nothing
+// in ModeReplay.
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(data, '\n'), 0o644)
+}
+
+// Sanitize redacts the SecretHeaders and SecretParams found in the URL,
+// headers and bodies of an interaction.
+func Sanitize(in *Interaction) {
+	in.Request.URL = sanitizeURL(in.Request.URL)
+	in.Request.Body = sanitizeBody(in.Request.Body)
+	sanitizeHeader(in.Request.Header)
+	in.Response.Body = sanitizeBody(in.Response.Body)
+	sanitizeHeader(in.Response.Header)
+}
+
+func sanitizeHeader(h http.Header) {
+	for _, name := range SecretHeaders {
+		if h.Get(name) != "" {
+			h.Set(name, Redacted)
+		}
+	}
+}
+
+func sanitizeURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.RawQuery == "" {
+		return raw
+	}
+	q := u.Query()
+	if sanitizeValues(q) {
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// sanitizeBody redacts secrets in JSON objects and form encoded bodies. Other
+// bodies are left untouched.
+func sanitizeBody(body string) string {
+	if body == "" {
+		return body
+	}
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &obj); err == nil {
+		changed := false
+		for _, name := range SecretParams {
+			if _, ok := obj[name]; ok {
+				obj[name] = Redacted
+				changed = true
+			}
+		}
+		if !changed {
+			return body
+		}
+		b, err := json.Marshal(obj)
+		if err != nil {
+			return body
+		}
+		return string(b)
+	}
+
+	if form, err := url.ParseQuery(body); err == nil && sanitizeValues(form) {
+		return form.Encode()
+	}
+	return body
+}
+
+func sanitizeValues(values url.Values) bool {
+	changed := false
+	for _, name := range SecretParams {
+		if _, ok := values[name]; ok {
+			values.Set(name, Redacted)
+			changed = true
+		}
+	}
+	return changed
+}
+
+func readBody(body *io.ReadCloser) (string, error) {
+	if *body == nil || *body == http.NoBody {
+		return "", nil
+	}
+	b, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return "", err
+	}
+	*body = io.NopCloser(bytes.NewReader(b))
+	return string(b), nil
+}
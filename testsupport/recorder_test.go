@@ -0,0 +1,87 @@
+package testsupport_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/markbates/goth/testsupport"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RecordAndReplay(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"secret-token","token_type":"Bearer"}`)
+		case "/me":
+			fmt.Fprintf(w, `{"id":"%d"}`, calls)
+		}
+	}))
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	rec, err := testsupport.New(path, testsupport.ModeRecord)
+	a.NoError(err)
+	client := rec.Client()
+
+	res, err := client.PostForm(ts.URL+"/token", url.Values{"code": {"abc"}, "client_secret": {"shh"}, "grant_type": {"authorization_code"}})
+	a.NoError(err)
+	body, _ := io.ReadAll(res.Body)
+	a.Contains(string(body), "secret-token")
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", ts.URL+"/me?access_token=secret-token", nil)
+		req.Header.Set("Authorization", "Bearer secret-token")
+		_, err = client.Do(req)
+		a.NoError(err)
+	}
+	a.NoError(rec.Save())
+
+	data, err := os.ReadFile(path)
+	a.NoError(err)
+	a.NotContains(string(data), "secret-token")
+	a.NotContains(string(data), "shh")
+	a.Contains(string(data), "grant_type=authorization_code")
+
+	ts.Close()
+	rec, err = testsupport.New(path, testsupport.ModeReplay)
+	a.NoError(err)
+	client = rec.Client()
+
+	res, err = client.PostForm(ts.URL+"/token", url.Values{"code": {"other"}, "client_secret": {"other"}, "grant_type": {"authorization_code"}})
+	a.NoError(err)
+	body, _ = io.ReadAll(res.Body)
+	a.Equal(`{"access_token":"REDACTED","token_type":"Bearer"}`, string(body))
+	a.Equal("application/json", res.Header.Get("Content-Type"))
+
+	for _, want := range []string{`{"id":"2"}`, `{"id":"3"}`} {
+		res, err = client.Get(ts.URL + "/me?access_token=another-token")
+		a.NoError(err)
+		body, _ = io.ReadAll(res.Body)
+		a.Equal(want, string(body))
+	}
+
+	_, err = client.Get(ts.URL + "/me?access_token=another-token")
+	a.Error(err)
+	a.True(strings.Contains(err.Error(), "no recorded interaction"))
+}
+
+func Test_NewReplayMissingFixture(t *testing.T) {
+	t.Parallel()
+
+	_, err := testsupport.New(filepath.Join(t.TempDir(), "missing.json"), testsupport.ModeReplay)
+	assert.Error(t, err)
+}
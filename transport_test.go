@@ -0,0 +1,91 @@
+package goth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SetFallbackTransport(t *testing.T) {
+	a := assert.New(t)
+	defer func(c *http.Client) { fallbackClient = c }(fallbackClient)
+
+	a.Equal(http.DefaultClient, HTTPClientWithFallBack(nil))
+
+	SetFallbackTransport(TransportOptions{MaxIdleConnsPerHost: 64, IdleConnTimeout: time.Minute})
+	c := HTTPClientWithFallBack(nil)
+	a.NotEqual(http.DefaultClient, c)
+	tr := c.Transport.(*http.Transport)
+	a.Equal(64, tr.MaxIdleConnsPerHost)
+	a.Equal(time.Minute, tr.IdleConnTimeout)
+	a.True(tr.ForceAttemptHTTP2)
+
+	SetFallbackTransport(TransportOptions{DisableHTTP2: true})
+	tr = HTTPClientWithFallBack(nil).Transport.(*http.Transport)
+	a.False(tr.ForceAttemptHTTP2)
+	a.NotNil(tr.TLSNextProto)
+
+	own := &http.Client{}
+	a.Equal(own, HTTPClientWithFallBack(own))
+
+	// http.DefaultTransport may have been replaced, e.g. by a mock
+	defer func(t http.RoundTripper) { http.DefaultTransport = t }(http.DefaultTransport)
+	http.DefaultTransport = roundTripperFunc(func(*http.Request) (*http.Response, error) { return nil, nil })
+	SetFallbackTransport(TransportOptions{MaxIdleConnsPerHost: 8})
+	tr = HTTPClientWithFallBack(nil).Transport.(*http.Transport)
+	a.Equal(8, tr.MaxIdleConnsPerHost)
+	a.Equal(100, tr.MaxIdleConns)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func Test_SetUserAgent(t *testing.T) {
+	a := assert.New(t)
+	defer func(ua string) { userAgent = ua }(userAgent)
+
+	var got []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("User-Agent"))
+	}))
+	defer ts.Close()
+
+	get := func(c *http.Client, ua string) {
+		req, err := http.NewRequest("GET", ts.URL, nil)
+		a.NoError(err)
+		if ua != "" {
+			req.Header.Set("User-Agent", ua)
+		}
+		resp, err := c.Do(req)
+		a.NoError(err)
+		resp.Body.Close()
+	}
+
+	own := &http.Client{}
+	get(UserAgentClient(nil), "")
+	get(UserAgentClient(own), "")
+	get(UserAgentClient(nil), "custom/1.0")
+	SetUserAgent("app/2.0")
+	get(UserAgentClient(own), "")
+	get(WithUserAgent(HTTPClientWithFallBack(own), "provider/3.0"), "")
+	SetUserAgent("")
+	get(UserAgentClient(nil), "")
+
+	a.Equal(DefaultUserAgent, got[0])
+	a.Equal(DefaultUserAgent, got[1])
+	a.Equal("custom/1.0", got[2])
+	a.Equal("app/2.0", got[3])
+	a.Equal("provider/3.0", got[4])
+	a.True(strings.HasPrefix(got[5], "Go-http-client/"))
+	a.Nil(own.Transport)
+	// HTTPClientWithFallBack is left alone
+	SetUserAgent("app/2.0")
+	a.Equal(own, HTTPClientWithFallBack(own))
+}
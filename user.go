@@ -2,13 +2,24 @@ package goth
 
 import (
 	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 	"time"
 )
 
 func init() {
 	gob.Register(User{})
+	// the nested values of RawData, as decoded from JSON
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
 }
 
+// ErrEmailNotVerified is returned by providers that have been told to require
+// a verified email address when the provider reports it as unverified.
+var ErrEmailNotVerified = errors.New("email address has not been verified by the provider")
+
 // User contains the information common amongst most OAuth and OAuth2 providers.
 // All the "raw" data from the provider can be found in the `RawData` field.
 type User struct {
@@ -28,4 +39,275 @@ type User struct {
 	RefreshToken      string
 	ExpiresAt         time.Time
 	IDToken           string
+	Avatars           []Avatar
+}
+
+// Avatar is a user picture of a given size. Providers offering several sizes
+// list them in User.Avatars, AvatarURL being one of them.
+type Avatar struct {
+	URL string
+	// Size is the width and height of the picture in pixels, or zero when
+	// unknown.
+	Size int
+}
+
+// Expired reports whether the access token of the user has expired. It
+// returns false when the expiry is unknown.
+func (u User) Expired() bool {
+	return u.ExpiresWithin(0)
+}
+
+// ExpiresWithin reports whether the access token of the user expires within
+// d, which is useful to refresh tokens before they expire. It returns false
+// when the expiry is unknown.
+func (u User) ExpiresWithin(d time.Duration) bool {
+	if u.ExpiresAt.IsZero() {
+		return false
+	}
+	return time.Now().Add(d).After(u.ExpiresAt)
+}
+
+// ErrIncompleteUserKey is returned by User.KeyOrErr when the user has no
+// Provider or no UserID.
+var ErrIncompleteUserKey = errors.New("user key requires both Provider and UserID")
+
+// Key returns a stable identifier for the user, "<provider>:<user id>", that
+// is unique across providers. Use KeyOrErr to make sure neither part is empty.
+func (u User) Key() string {
+	return fmt.Sprintf("%s:%s", u.Provider, u.UserID)
+}
+
+// KeyOrErr returns the same value as Key, or ErrIncompleteUserKey when
+// Provider or UserID is empty.
+func (u User) KeyOrErr() (string, error) {
+	if u.Provider == "" || u.UserID == "" {
+		return "", ErrIncompleteUserKey
+	}
+	return u.Key(), nil
+}
+
+// redacted replaces the tokens of a user in Redacted.
+const redacted = "<redacted>"
+
+// rawDataTokens are the RawData keys under which some providers keep the
+// tokens of the user, dropped by Redacted.
+var rawDataTokens = []string{"access_token", "refresh_token", "id_token"}
+
+// Redacted returns a copy of the user whose tokens and token secret, when
+// set, are replaced by "<redacted>", so that it can be logged or sent to a
+// client safely. RawData is copied without its access_token, refresh_token
+// and id_token entries; other values, including nested maps, are shared
+// with u.
+func (u User) Redacted() User {
+	for _, token := range []*string{&u.AccessToken, &u.AccessTokenSecret, &u.RefreshToken, &u.IDToken} {
+		if *token != "" {
+			*token = redacted
+		}
+	}
+	if u.RawData != nil {
+		raw := make(map[string]interface{}, len(u.RawData))
+		for k, v := range u.RawData {
+			raw[k] = v
+		}
+		for _, k := range rawDataTokens {
+			delete(raw, k)
+		}
+		u.RawData = raw
+	}
+	return u
+}
+
+// Clone returns a deep copy of the user, whose RawData and Avatars can be
+// changed without changing u, e.g. when a cached user is handed to several
+// goroutines. Maps, slices, arrays and structs nested in RawData are copied
+// too, whether decoded from JSON or typed values stored by a provider like
+// []google.Email; pointers and unexported struct fields are shared.
+func (u User) Clone() User {
+	if u.RawData != nil {
+		u.RawData = cloneRaw(u.RawData).(map[string]interface{})
+	}
+	if u.Avatars != nil {
+		u.Avatars = append([]Avatar(nil), u.Avatars...)
+	}
+	return u
+}
+
+// cloneRaw deep copies the maps and slices of a RawData value.
+func cloneRaw(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			c[k] = cloneRaw(e)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, e := range v {
+			c[i] = cloneRaw(e)
+		}
+		return c
+	}
+	// typed values stored by providers, e.g. a slice of structs
+	return cloneValue(reflect.ValueOf(v)).Interface()
+}
+
+// cloneValue deep copies the maps, slices, arrays and structs of v. Pointers,
+// channels and functions are shared, as are unexported struct fields.
+func cloneValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), cloneValue(iter.Value()))
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(cloneValue(v.Index(i)))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(cloneValue(v.Index(i)))
+		}
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(cloneValue(v.Elem()))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < c.NumField(); i++ {
+			if f := c.Field(i); f.CanSet() {
+				f.Set(cloneValue(v.Field(i)))
+			}
+		}
+		return c
+	}
+	return v
+}
+
+// MergeUsers returns a copy of base enriched with the profile data found in
+// incoming. This is useful for account linking, when the same person signs in
+// through more than one provider.
+//
+// Profile fields (name, email, avatar, ...) are only copied from incoming when
+// they are empty in base; existing data is never overwritten. The identity and
+// token fields (Provider, UserID, tokens and expiry) always come from base.
+// RawData maps are merged, with keys already present in base being stored
+// under "<incoming provider>.<key>" instead. Existing keys are never
+// overwritten: an incoming value whose namespaced key is taken too, e.g. by a
+// "github.id" key of base, is dropped.
+func MergeUsers(base, incoming User) User {
+	merged := base
+
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fill(&merged.Email, incoming.Email)
+	fill(&merged.Name, incoming.Name)
+	fill(&merged.FirstName, incoming.FirstName)
+	fill(&merged.LastName, incoming.LastName)
+	fill(&merged.NickName, incoming.NickName)
+	fill(&merged.Description, incoming.Description)
+	fill(&merged.AvatarURL, incoming.AvatarURL)
+	fill(&merged.Location, incoming.Location)
+	// keep the avatars consistent with AvatarURL
+	if base.AvatarURL == "" && len(base.Avatars) == 0 {
+		merged.Avatars = incoming.Avatars
+	}
+
+	if base.RawData == nil && incoming.RawData == nil {
+		return merged
+	}
+
+	merged.RawData = make(map[string]interface{}, len(base.RawData)+len(incoming.RawData))
+	for k, v := range base.RawData {
+		merged.RawData[k] = v
+	}
+
+	prefix := incoming.Provider
+	if prefix == "" {
+		prefix = "incoming"
+	}
+	var colliding []string
+	for k, v := range incoming.RawData {
+		if _, ok := base.RawData[k]; ok {
+			colliding = append(colliding, k)
+			continue
+		}
+		merged.RawData[k] = v
+	}
+	// namespaced once all the other keys are in, so that they never
+	// overwrite one whatever the order of the maps
+	for _, k := range colliding {
+		if _, ok := merged.RawData[prefix+"."+k]; !ok {
+			merged.RawData[prefix+"."+k] = incoming.RawData[k]
+		}
+	}
+
+	return merged
+}
+
+// MissingFieldError is returned by User.Require when a required field is
+// empty.
+type MissingFieldError struct {
+	Field string
+}
+
+func (e *MissingFieldError) Error() string {
+	return "missing required field: " + e.Field
+}
+
+// Require returns a *MissingFieldError for the first of the given profile
+// fields that is empty. Fields are named after the User struct fields,
+// ignoring case, e.g. "email" or "AvatarURL".
+func (u User) Require(fields ...string) error {
+	for _, field := range fields {
+		var value string
+		switch strings.ToLower(field) {
+		case "email":
+			value = u.Email
+		case "name":
+			value = u.Name
+		case "firstname":
+			value = u.FirstName
+		case "lastname":
+			value = u.LastName
+		case "nickname":
+			value = u.NickName
+		case "description":
+			value = u.Description
+		case "userid":
+			value = u.UserID
+		case "avatarurl":
+			value = u.AvatarURL
+		case "location":
+			value = u.Location
+		default:
+			return fmt.Errorf("unknown user field: %s", field)
+		}
+		if value == "" {
+			return &MissingFieldError{Field: field}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,24 @@
+//go:build go1.21
+// +build go1.21
+
+package goth
+
+import "log/slog"
+
+// LogValue implements slog.LogValuer, so that users logged with log/slog never
+// leak their tokens. Only the identity and profile fields are logged, tokens
+// being shown as "<redacted>" when set.
+func (u User) LogValue() slog.Value {
+	r := u.Redacted()
+	return slog.GroupValue(
+		slog.String("provider", r.Provider),
+		slog.String("user_id", r.UserID),
+		slog.String("email", r.Email),
+		slog.String("name", r.Name),
+		slog.String("access_token", r.AccessToken),
+		slog.String("access_token_secret", r.AccessTokenSecret),
+		slog.String("refresh_token", r.RefreshToken),
+		slog.String("id_token", r.IDToken),
+		slog.Time("expires_at", r.ExpiresAt),
+	)
+}
@@ -0,0 +1,25 @@
+//go:build go1.21
+// +build go1.21
+
+package goth_test
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_UserLogValue(t *testing.T) {
+	a := assert.New(t)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	logger.Info("login", "user", goth.User{Provider: "faux", UserID: "1", AccessToken: "secret-access", RefreshToken: "secret-refresh"})
+
+	a.Contains(buf.String(), "user.user_id=1")
+	a.Contains(buf.String(), "user.access_token=<redacted>")
+	a.NotContains(buf.String(), "secret-")
+}
@@ -1 +1,194 @@
 package goth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MergeUsers(t *testing.T) {
+	a := assert.New(t)
+
+	base := goth.User{
+		Provider:    "google",
+		UserID:      "123",
+		Email:       "homer@example.com",
+		AccessToken: "google-token",
+		RawData:     map[string]interface{}{"id": "123", "hd": "example.com"},
+	}
+	incoming := goth.User{
+		Provider:    "github",
+		UserID:      "456",
+		Email:       "homer@springfield.com",
+		Name:        "Homer Simpson",
+		AvatarURL:   "https://example.com/homer.png",
+		Avatars:     []goth.Avatar{{URL: "https://example.com/homer.png", Size: 460}},
+		AccessToken: "github-token",
+		RawData:     map[string]interface{}{"id": "456", "login": "homer"},
+	}
+
+	merged := goth.MergeUsers(base, incoming)
+	a.Equal("google", merged.Provider)
+	a.Equal("123", merged.UserID)
+	a.Equal("google-token", merged.AccessToken)
+	a.Equal("homer@example.com", merged.Email)
+	a.Equal("Homer Simpson", merged.Name)
+	a.Equal("https://example.com/homer.png", merged.AvatarURL)
+	a.Equal(incoming.Avatars, merged.Avatars)
+	a.Equal(map[string]interface{}{
+		"id":        "123",
+		"hd":        "example.com",
+		"login":     "homer",
+		"github.id": "456",
+	}, merged.RawData)
+
+	// the base user must not be modified
+	a.Equal("", base.Name)
+	a.Len(base.RawData, 2)
+}
+
+func Test_MergeUsersNamespaceCollision(t *testing.T) {
+	a := assert.New(t)
+
+	base := goth.User{RawData: map[string]interface{}{"id": "123", "github.id": "linked"}}
+	incoming := goth.User{Provider: "github", RawData: map[string]interface{}{"id": "456", "login": "homer"}}
+	merged := goth.MergeUsers(base, incoming)
+	a.Equal(map[string]interface{}{
+		"id":        "123",
+		"github.id": "linked",
+		"login":     "homer",
+	}, merged.RawData)
+
+	// an incoming key spelled like a namespaced one wins over the namespacing
+	base = goth.User{RawData: map[string]interface{}{"id": "123"}}
+	incoming = goth.User{Provider: "github", RawData: map[string]interface{}{"id": "456", "github.id": "literal"}}
+	merged = goth.MergeUsers(base, incoming)
+	a.Equal(map[string]interface{}{
+		"id":        "123",
+		"github.id": "literal",
+	}, merged.RawData)
+}
+
+func Test_MergeUsersNoRawData(t *testing.T) {
+	a := assert.New(t)
+
+	merged := goth.MergeUsers(goth.User{Name: "Homer"}, goth.User{Name: "Marge", Email: "marge@example.com"})
+	a.Equal("Homer", merged.Name)
+	a.Equal("marge@example.com", merged.Email)
+	a.Nil(merged.RawData)
+}
+
+func Test_UserKey(t *testing.T) {
+	a := assert.New(t)
+
+	u := goth.User{Provider: "google", UserID: "1234"}
+	a.Equal("google:1234", u.Key())
+
+	key, err := u.KeyOrErr()
+	a.NoError(err)
+	a.Equal("google:1234", key)
+
+	_, err = goth.User{Provider: "google"}.KeyOrErr()
+	a.Equal(goth.ErrIncompleteUserKey, err)
+
+	_, err = goth.User{UserID: "1234"}.KeyOrErr()
+	a.Equal(goth.ErrIncompleteUserKey, err)
+}
+
+func Test_UserExpired(t *testing.T) {
+	a := assert.New(t)
+
+	a.False(goth.User{}.Expired())
+	a.False(goth.User{}.ExpiresWithin(time.Hour))
+
+	a.True(goth.User{ExpiresAt: time.Now().Add(-time.Minute)}.Expired())
+
+	u := goth.User{ExpiresAt: time.Now().Add(time.Minute)}
+	a.False(u.Expired())
+	a.True(u.ExpiresWithin(5 * time.Minute))
+	a.False(u.ExpiresWithin(30 * time.Second))
+}
+
+func Test_UserRequire(t *testing.T) {
+	a := assert.New(t)
+
+	u := goth.User{Name: "Homer", UserID: "1"}
+	a.NoError(u.Require("name", "UserID"))
+
+	err := u.Require("name", "email")
+	a.EqualError(err, "missing required field: email")
+	var missing *goth.MissingFieldError
+	a.ErrorAs(err, &missing)
+	a.Equal("email", missing.Field)
+
+	a.EqualError(u.Require("age"), "unknown user field: age")
+}
+
+func Test_UserRedacted(t *testing.T) {
+	a := assert.New(t)
+
+	u := goth.User{
+		Provider:     "faux",
+		UserID:       "1",
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		IDToken:      "id",
+		RawData:      map[string]interface{}{"access_token": "access", "id_token": "id", "email": "homer@example.com"},
+	}
+	r := u.Redacted()
+	a.Equal("<redacted>", r.AccessToken)
+	a.Equal("<redacted>", r.RefreshToken)
+	a.Equal("<redacted>", r.IDToken)
+	a.Empty(r.AccessTokenSecret)
+	a.Equal("1", r.UserID)
+	a.Equal(map[string]interface{}{"email": "homer@example.com"}, r.RawData)
+	// the original is left untouched
+	a.Equal("access", u.AccessToken)
+	a.Equal("access", u.RawData["access_token"])
+}
+
+// testEmail stands for the typed values providers store in RawData.
+type testEmail struct {
+	Value string
+	Tags  []string
+}
+
+func Test_UserClone(t *testing.T) {
+	a := assert.New(t)
+
+	u := goth.User{
+		UserID: "1",
+		RawData: map[string]interface{}{
+			"name":   "Homer",
+			"groups": []interface{}{"admins"},
+			"org":    map[string]interface{}{"name": "Springfield"},
+			"emails": []string{"homer@example.com"},
+			"typed":  []testEmail{{Value: "homer@example.com", Tags: []string{"work"}}},
+			"labels": map[string]string{"team": "safety"},
+		},
+		Avatars: []goth.Avatar{{URL: "https://example.com/homer.png"}},
+	}
+	c := u.Clone()
+	a.Equal(u, c)
+
+	c.RawData["name"] = "Bart"
+	c.RawData["groups"].([]interface{})[0] = "users"
+	c.RawData["org"].(map[string]interface{})["name"] = "Shelbyville"
+	c.RawData["emails"].([]string)[0] = "bart@example.com"
+	c.RawData["typed"].([]testEmail)[0].Value = "bart@example.com"
+	c.RawData["typed"].([]testEmail)[0].Tags[0] = "home"
+	c.RawData["labels"].(map[string]string)["team"] = "school"
+	c.Avatars[0].URL = "https://example.com/bart.png"
+
+	a.Equal("Homer", u.RawData["name"])
+	a.Equal([]interface{}{"admins"}, u.RawData["groups"])
+	a.Equal(map[string]interface{}{"name": "Springfield"}, u.RawData["org"])
+	a.Equal([]string{"homer@example.com"}, u.RawData["emails"])
+	a.Equal([]testEmail{{Value: "homer@example.com", Tags: []string{"work"}}}, u.RawData["typed"])
+	a.Equal(map[string]string{"team": "safety"}, u.RawData["labels"])
+	a.Equal("https://example.com/homer.png", u.Avatars[0].URL)
+
+	a.Nil(goth.User{}.Clone().RawData)
+}